@@ -1,6 +1,9 @@
 package main
 
 import (
+	"os"
+
+	"github.com/LaunchPad-Network/NetPeek/internal/cli"
 	"github.com/LaunchPad-Network/NetPeek/internal/logger"
 	"github.com/LaunchPad-Network/NetPeek/internal/misc/banner"
 	"github.com/LaunchPad-Network/NetPeek/internal/misc/communityparser"
@@ -13,6 +16,10 @@ import (
 var log = logger.New("Main")
 
 func main() {
+	if len(os.Args) > 1 {
+		os.Exit(cli.Run(os.Args[1:], frontend.CheckConfig))
+	}
+
 	banner.PrintBanner("")
 
 	stopChan := make(chan struct{})
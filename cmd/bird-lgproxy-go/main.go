@@ -1,6 +1,9 @@
 package main
 
 import (
+	"os"
+
+	"github.com/LaunchPad-Network/NetPeek/internal/cli"
 	"github.com/LaunchPad-Network/NetPeek/internal/logger"
 	"github.com/LaunchPad-Network/NetPeek/internal/misc/banner"
 	"github.com/LaunchPad-Network/NetPeek/internal/service/proxy"
@@ -11,12 +14,15 @@ import (
 var log = logger.New("Main")
 
 func main() {
+	if len(os.Args) > 1 {
+		os.Exit(cli.Run(os.Args[1:], proxy.CheckConfig))
+	}
+
 	banner.PrintBanner("Proxy")
 
 	r := proxy.SetupRouter()
 
-	log.Info("Listening on " + viperx.GetString("net.host", "0.0.0.0") + ":" + viperx.GetString("net.port", "10179"))
-	err := r.Run(viperx.GetString("net.host", "0.0.0.0") + ":" + viperx.GetString("net.port", "10179"))
+	err := proxy.ListenAndServe(r, viperx.GetString("net.host", "0.0.0.0")+":"+viperx.GetString("net.port", "10179"))
 
 	if err != nil {
 		log.Fatal(err)
@@ -0,0 +1,9 @@
+package constant
+
+const (
+	ProxyReqReplayCacheSize = 100000
+	ProxyRequestMaxBodySize = 64 << 10
+
+	TraceroutePTRWorkers    = 8
+	TracerouteOriginWorkers = 4
+)
@@ -6,8 +6,37 @@ const (
 	TimeFormat = "2006-01-02 15:04:05"
 
 	ProxyReqSignValidityDuration = 30 * time.Second
+	ProxyReqSignMaxClockSkew     = 5 * time.Second
 
 	ServersListPullInterval     = 10 * time.Minute
 	ServersListMinPullInterval  = 1 * time.Minute
 	BGPCommunityDefPullInterval = 10 * time.Minute
+
+	BirdConnMaxIdle   = 1 * time.Minute
+	BirdStatusTimeout = 5 * time.Second
+
+	InstancesCacheDuration       = 10 * time.Minute
+	InstancesFailedCacheDuration = 1 * time.Minute
+
+	TLSReloadCheckInterval = 10 * time.Second
+
+	TunnelDialTimeout   = 10 * time.Second
+	TunnelPingInterval  = 30 * time.Second
+	TunnelPingTimeout   = 10 * time.Second
+	TunnelRetryMinDelay = 1 * time.Second
+	TunnelRetryMaxDelay = 1 * time.Minute
+
+	TracerouteResolveTimeout            = 5 * time.Second
+	TracerouteOriginTimeout             = 2 * time.Second
+	TracerouteOriginCacheDuration       = 10 * time.Minute
+	TracerouteOriginFailedCacheDuration = 1 * time.Minute
+	TraceroutePTRTimeout                = 2 * time.Second
+	TraceroutePTRCacheDuration          = 1 * time.Hour
+	TraceroutePTRFailedCacheDuration    = 5 * time.Minute
+	TracerouteSourceCacheDuration       = 10 * time.Second
+
+	ASNameCacheDuration = 1 * time.Hour
+
+	CapabilitiesCacheDuration       = 5 * time.Minute
+	CapabilitiesFailedCacheDuration = 1 * time.Minute
 )
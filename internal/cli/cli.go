@@ -0,0 +1,262 @@
+// Package cli implements the subcommands shared by bird-lg-go and
+// bird-lgproxy-go besides serving.
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/crypto/hmac"
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/crypto/signature"
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/proxyapi"
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/proxyreqsign"
+)
+
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) error
+}
+
+// Run executes the subcommand named by args[0] and returns the exit code.
+// checkConfig validates the configuration of the calling binary.
+func Run(args []string, checkConfig func() error) int {
+	commands := []command{
+		{"keygen", "generate a signing key pair", keygen},
+		{"pubkey", "print the public key of a private key file", pubkey},
+		{"sign", "sign a proxy request with the configured key", sign},
+		{"verify", "verify a signed proxy request against the trusted keys", verify},
+		{"check-config", "validate the configuration and keys", func(args []string) error {
+			if err := checkConfig(); err != nil {
+				return err
+			}
+			fmt.Println("Configuration OK")
+			return nil
+		}},
+	}
+
+	for _, cmd := range commands {
+		if cmd.name == args[0] {
+			if err := cmd.run(args[1:]); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", cmd.name, err)
+				return 1
+			}
+			return 0
+		}
+	}
+
+	out := os.Stderr
+	code := 2
+	if args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		out = os.Stdout
+		code = 0
+	} else {
+		fmt.Fprintf(out, "unknown command %q\n", args[0])
+	}
+	fmt.Fprintf(out, "Usage: %s [command] [flags]\n\nWithout a command the server is started.\n\nCommands:\n", os.Args[0])
+	for _, cmd := range commands {
+		fmt.Fprintf(out, "  %-14s %s\n", cmd.name, cmd.usage)
+	}
+	return code
+}
+
+func newFlagSet(name, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s %s [flags] %s\n", os.Args[0], name, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+func keygen(args []string) error {
+	fs := newFlagSet("keygen", "")
+	alg := fs.String("algorithm", proxyreqsign.Algorithm(), "signature algorithm: "+strings.Join(signature.Algorithms, ", "))
+	format := fs.String("format", signature.FormatPEM, "key file format: pem or hex")
+	out := fs.String("out", "netpeek", "writes <out>.key and <out>.pub")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	privHex, pubHex, err := signature.GenerateKey(*alg)
+	if err != nil {
+		return err
+	}
+	privData, err := signature.EncodeKey(*alg, privHex, true, *format)
+	if err != nil {
+		return err
+	}
+	if err := signature.WriteKeyFile(*out+".key", privData, true); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %s key to %s.key\n", *alg, *out)
+
+	// HMAC keys are a shared secret, there is no public half to hand out
+	if *alg != hmac.Algorithm {
+		pubData, err := signature.EncodeKey(*alg, pubHex, false, *format)
+		if err != nil {
+			return err
+		}
+		if err := signature.WriteKeyFile(*out+".pub", pubData, false); err != nil {
+			return err
+		}
+		fmt.Printf("Wrote public key to %s.pub\n", *out)
+	}
+	fmt.Printf("Key ID: %s\n", proxyreqsign.KeyID(pubHex))
+	return nil
+}
+
+func pubkey(args []string) error {
+	fs := newFlagSet("pubkey", "<private key file | ->")
+	alg := fs.String("algorithm", proxyreqsign.Algorithm(), "signature algorithm of the key")
+	format := fs.String("format", signature.FormatPEM, "output format: pem or hex")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected one key file")
+	}
+
+	var privHex string
+	var err error
+	if fs.Arg(0) == "-" {
+		var data []byte
+		data, err = io.ReadAll(os.Stdin)
+		if err == nil {
+			privHex, err = signature.DecodeKey(data)
+		}
+	} else {
+		privHex, err = signature.ReadKeyFile(fs.Arg(0))
+	}
+	if err != nil {
+		return err
+	}
+
+	pubHex, err := signature.PublicKeyHex(*alg, privHex)
+	if err != nil {
+		return err
+	}
+	data, err := signature.EncodeKey(*alg, pubHex, false, *format)
+	if err != nil {
+		return err
+	}
+	os.Stdout.Write(data)
+	fmt.Fprintf(os.Stderr, "Key ID: %s\n", proxyreqsign.KeyID(pubHex))
+	return nil
+}
+
+func sign(args []string) error {
+	fs := newFlagSet("sign", "<JSON request | query of -kind>")
+	kind := fs.String("kind", "", "legacy GET endpoint, e.g. bird or traceroute, instead of /query (needs proxy.legacy_endpoints)")
+	node := fs.String("node", "", "PoP ID the request is meant for")
+	instance := fs.String("instance", "", "BIRD instance of legacy requests")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 || *node == "" {
+		fs.Usage()
+		return fmt.Errorf("expected -node and one request")
+	}
+
+	if err := proxyreqsign.LoadSigningKey(); err != nil {
+		return err
+	}
+	if *kind != "" {
+		spr, err := proxyreqsign.Sign(http.MethodGet, *kind, *node, fs.Arg(0), *instance)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s /%s?%s\n", spr.Method, spr.Kind, spr.Params().Encode())
+		return nil
+	}
+
+	body := []byte(fs.Arg(0))
+	if _, err := proxyapi.Decode(body); err != nil {
+		return err
+	}
+	spr, err := proxyreqsign.SignBody("query", *node, body)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s /%s?%s\n%s\n", spr.Method, spr.Kind, spr.Params().Encode(), body)
+	return nil
+}
+
+func verify(args []string) error {
+	fs := newFlagSet("verify", "<request URL or query string>")
+	kind := fs.String("kind", "", "proxy endpoint, defaults to the URL path")
+	body := fs.String("body", "", "body of a POST request")
+	node := fs.String("node", "", "PoP ID of this proxy, defaults to the node of the request")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected one request")
+	}
+
+	u, err := url.Parse(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	params := u.Query()
+	if u.RawQuery == "" {
+		params, err = url.ParseQuery(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+	}
+	if *kind == "" {
+		*kind = strings.TrimPrefix(u.Path, "/")
+	}
+	if *node == "" {
+		*node = params.Get("node")
+	}
+	ts, err := strconv.ParseInt(params.Get("ts"), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid ts: %w", err)
+	}
+
+	if err := proxyreqsign.LoadTrustedKeys(); err != nil {
+		return err
+	}
+	method := http.MethodGet
+	var bodyData []byte
+	if *body != "" {
+		method = http.MethodPost
+		bodyData = []byte(*body)
+	}
+	spr := &proxyreqsign.SignedProxyRequest{
+		Version:      params.Get("v"),
+		Method:       method,
+		Kind:         *kind,
+		Node:         params.Get("node"),
+		KeyID:        params.Get("kid"),
+		Query:        params.Get("q"),
+		Instance:     params.Get("instance"),
+		Nonce:        params.Get("n"),
+		Ts:           ts,
+		Signature:    params.Get("sig"),
+		Body:         bodyData,
+		Unrestricted: params.Get("unrestricted") == "1",
+	}
+	if err := spr.Verify(*node); err != nil {
+		return fmt.Errorf("rejected (%s): %w", proxyreqsign.RejectionReason(err), err)
+	}
+
+	scope := spr.Scope()
+	endpoints := "all"
+	if len(scope.Endpoints) > 0 {
+		endpoints = strings.Join(scope.Endpoints, ", ")
+	}
+	fmt.Printf("Valid %s request signed by key %s\n", spr.Kind, spr.KeyID)
+	fmt.Printf("Key scope: endpoints %s, unrestricted %t\n", endpoints, scope.Unrestricted)
+	return nil
+}
@@ -28,6 +28,8 @@ func initEnv() {
 		viper.BindEnv("net.port")
 		viper.BindEnv("authentication.privatekey")
 		viper.BindEnv("authentication.publickey")
+		viper.BindEnv("authentication.privatekey_file")
+		viper.BindEnv("authentication.publickey_file")
 		viper.BindEnv("log.level")
 		viper.BindEnv("traceroute.disable")
 	})
@@ -1,110 +1,97 @@
 package bird
 
 import (
-	"bytes"
+	"bufio"
+	"context"
 	"io"
-	"net"
-
-	"github.com/lfcypo/viperx"
 )
 
-const MAX_LINE_SIZE = 1024
+// newLineReader reads lines from bird socket through a buffer
+func newLineReader(bird io.Reader) lineReader {
+	r := bufio.NewReader(bird)
+	return func() (string, error) {
+		return r.ReadString('\n')
+	}
+}
 
-// Check if a byte is character for number
-func isNumeric(b byte) bool {
-	return b >= byte('0') && b <= byte('9')
+// Write a command to a bird socket
+func birdWriteln(bird io.Writer, s string) error {
+	_, err := bird.Write([]byte(s + "\n"))
+	return err
 }
 
-// Read a line from bird socket, removing preceding status number, output it.
-// Returns if there are more lines.
-func birdReadln(bird io.Reader, w io.Writer) bool {
-	// Read from socket byte by byte, until reaching newline character
-	c := make([]byte, MAX_LINE_SIZE)
-	pos := 0
-	for {
-		// Leave one byte for newline character
-		if pos >= MAX_LINE_SIZE-1 {
-			break
-		}
-		_, err := bird.Read(c[pos : pos+1])
-		if err != nil {
-			w.Write([]byte(err.Error()))
-			return false
-		}
-		if c[pos] == byte('\n') {
-			break
-		}
-		pos++
+// writeLines returns a callback for readReply that prints data lines to w
+func writeLines(w io.Writer) func(Line) error {
+	return func(line Line) error {
+		_, err := io.WriteString(w, line.Text+"\n")
+		return err
 	}
+}
 
-	c = c[:pos+1]
-	c[pos] = '\n'
-	// print(string(c[:]))
-
-	// Remove preceding status number, different situations
-	if pos > 4 && isNumeric(c[0]) && isNumeric(c[1]) && isNumeric(c[2]) && isNumeric(c[3]) {
-		// There is a status number at beginning, remove first 5 bytes
-		if w != nil && pos > 6 {
-			pos = 5
-			w.Write(c[pos:])
-		}
-		return c[0] != byte('0') && c[0] != byte('8') && c[0] != byte('9')
-	} else {
-		if w != nil {
-			w.Write(c[1:])
-		}
-		return true
+// query sends a command and streams the reply text to output.
+// A failed final status is returned as a *ReplyError, output cut off at
+// the limits ends with a marker line and returns ErrTruncated.
+func query(next lineReader, bird io.Writer, q string, output io.Writer, limits Limits) error {
+	if err := birdWriteln(bird, q); err != nil {
+		return err
 	}
-}
 
-// Write a command to a bird socket
-func birdWriteln(bird io.Writer, s string) {
-	bird.Write([]byte(s + "\n"))
+	status, err := readReply(next, limitLines(limits, output, writeLines(output)))
+	if err != nil {
+		return err
+	}
+	if err := status.Err(); err != nil {
+		return err
+	}
+	if status.Text != "" {
+		_, err = io.WriteString(output, status.Text+"\n")
+	}
+	return err
 }
 
-func getBirdSocket() (io.ReadWriter, error) {
-	bird, err := net.Dial("unix", viperx.GetString("bird.socket", "/var/run/bird/bird.ctl"))
+// handshake reads the initial greeting of a fresh connection
+func handshake(next lineReader) error {
+	status, err := readReply(next, nil)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	return bird, nil
+	return status.Err()
 }
 
-func CallBirdRestricted(query string, output io.Writer) error {
-	bird, err := getBirdSocket()
+// restrict switches the session into restricted mode and checks
+// that BIRD acknowledged it.
+func restrict(next lineReader, bird io.Writer) error {
+	if err := birdWriteln(bird, "restrict"); err != nil {
+		return err
+	}
+	status, err := readReply(next, nil)
 	if err != nil {
 		return err
 	}
-
-	// Read initial greeting
-	birdReadln(bird, nil)
-
-	// Send restrict command
-	birdWriteln(bird, "restrict")
-	var restrictedConfirmation bytes.Buffer
-	birdReadln(bird, &restrictedConfirmation)
-
-	// Send actual query
-	birdWriteln(bird, query)
-	// Read all output lines
-	for birdReadln(bird, output) {
+	if status.Code != CodeAccessRestricted {
+		return &ReplyError{
+			Code:    status.Code,
+			Message: status.Text,
+			Kind:    ErrRestrictNotAccepted,
+		}
 	}
 	return nil
 }
 
-func CallBirdUnrestricted(query string, output io.Writer) error {
-	bird, err := getBirdSocket()
+// CallBirdRestricted runs a query in restricted mode on the named instance
+func CallBirdRestricted(ctx context.Context, instance, q string, output io.Writer) error {
+	inst, err := GetInstance(instance)
 	if err != nil {
 		return err
 	}
+	return inst.Query(ctx, q, true, output)
+}
 
-	// Read initial greeting
-	birdReadln(bird, nil)
-
-	// Send actual query
-	birdWriteln(bird, query)
-	// Read all output lines
-	for birdReadln(bird, output) {
+// CallBirdUnrestricted runs a query with full privileges on the named instance
+func CallBirdUnrestricted(ctx context.Context, instance, q string, output io.Writer) error {
+	inst, err := GetInstance(instance)
+	if err != nil {
+		return err
 	}
-	return nil
+	return inst.Query(ctx, q, false, output)
 }
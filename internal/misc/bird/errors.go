@@ -0,0 +1,105 @@
+package bird
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	ErrSyntax              = errors.New("bird: syntax error")
+	ErrUnknownProtocol     = errors.New("bird: unknown protocol")
+	ErrAccessRestricted    = errors.New("bird: access restricted")
+	ErrTableNotFound       = errors.New("bird: table not found")
+	ErrRuntime             = errors.New("bird: runtime error")
+	ErrRestrictNotAccepted = errors.New("bird: restrict command not acknowledged")
+	ErrUnexpectedEOF       = errors.New("bird: connection closed before end of reply")
+)
+
+// Error classes used to carry a ReplyError across the proxy protocol
+const (
+	ClassSyntax              = "syntax"
+	ClassUnknownProtocol     = "unknown_protocol"
+	ClassAccessRestricted    = "access_restricted"
+	ClassTableNotFound       = "table_not_found"
+	ClassRuntime             = "runtime"
+	ClassRestrictNotAccepted = "restrict_not_accepted"
+
+	// ErrorClassHeader is the response header carrying the class
+	// of a failed query from the proxy to the frontend
+	ErrorClassHeader = "X-Bird-Error"
+)
+
+var classErrors = map[string]error{
+	ClassSyntax:              ErrSyntax,
+	ClassUnknownProtocol:     ErrUnknownProtocol,
+	ClassAccessRestricted:    ErrAccessRestricted,
+	ClassTableNotFound:       ErrTableNotFound,
+	ClassRuntime:             ErrRuntime,
+	ClassRestrictNotAccepted: ErrRestrictNotAccepted,
+}
+
+// ReplyError is a failure reported by BIRD in the final line of a reply.
+// It unwraps to one of the Err* sentinels, so callers can use errors.Is.
+type ReplyError struct {
+	Code    int
+	Message string
+	Kind    error
+}
+
+func (e *ReplyError) Error() string {
+	if e.Message == "" {
+		return e.Kind.Error()
+	}
+	return e.Kind.Error() + ": " + e.Message
+}
+
+func (e *ReplyError) Unwrap() error {
+	return e.Kind
+}
+
+// Class returns the wire name of the error kind
+func (e *ReplyError) Class() string {
+	for class, kind := range classErrors {
+		if kind == e.Kind {
+			return class
+		}
+	}
+	return ClassRuntime
+}
+
+// ErrorFromClass rebuilds a ReplyError from its wire class and message,
+// as sent by the proxy to the frontend.
+func ErrorFromClass(class, message string) *ReplyError {
+	kind, ok := classErrors[class]
+	if !ok {
+		kind = ErrRuntime
+	}
+	return &ReplyError{
+		Message: message,
+		Kind:    kind,
+	}
+}
+
+// classifyReply maps a final reply code and text to an error kind.
+// BIRD reuses the same codes for several failures, so the text is
+// consulted to tell unknown protocols and tables apart.
+func classifyReply(code int, text string) error {
+	lower := strings.ToLower(text)
+
+	switch {
+	case code == CodeAccessDenied:
+		return ErrAccessRestricted
+	case code == CodeNoProtocolsMatch,
+		strings.Contains(lower, "no protocol named"),
+		strings.Contains(lower, "is not a protocol"):
+		return ErrUnknownProtocol
+	case strings.Contains(lower, "is not a table"),
+		strings.Contains(lower, "no such table"),
+		strings.Contains(lower, "no table named"):
+		return ErrTableNotFound
+	case code >= 9000:
+		return ErrSyntax
+	default:
+		return ErrRuntime
+	}
+}
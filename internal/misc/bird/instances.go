@@ -0,0 +1,130 @@
+package bird
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lfcypo/viperx"
+	"github.com/spf13/viper"
+)
+
+// Address families an instance can serve
+const (
+	FamilyAny  = ""
+	FamilyIPv4 = "ipv4"
+	FamilyIPv6 = "ipv6"
+)
+
+// DefaultInstanceName names the instance built from bird.socket
+// when no bird.instances are configured
+const DefaultInstanceName = "bird"
+
+var ErrUnknownInstance = errors.New("bird: unknown instance")
+
+// Instance is one BIRD daemon the proxy can talk to, e.g. a BIRD 1.x
+// bird/bird6 pair or one BIRD per VRF.
+type Instance struct {
+	Name   string `json:"name"`
+	Family string `json:"family,omitempty"`
+	pool   *Pool
+}
+
+// Serves reports whether queries for the given address family should go
+// to this instance
+func (i *Instance) Serves(isV6 bool) bool {
+	switch i.Family {
+	case FamilyIPv4:
+		return !isV6
+	case FamilyIPv6:
+		return isV6
+	default:
+		return true
+	}
+}
+
+func (i *Instance) Query(ctx context.Context, q string, restricted bool, output io.Writer) error {
+	return i.pool.Query(ctx, q, restricted, output)
+}
+
+type instanceEntry struct {
+	Name     string `mapstructure:"name"`
+	Socket   string `mapstructure:"socket"`
+	Family   string `mapstructure:"family"`
+	PoolSize int    `mapstructure:"pool_size"`
+}
+
+type instancesConfig struct {
+	Bird struct {
+		Instances []instanceEntry `mapstructure:"instances"`
+	} `mapstructure:"bird"`
+}
+
+var instances []*Instance
+var instancesOnce sync.Once
+
+func loadInstances() {
+	var cfg instancesConfig
+	if err := viper.Unmarshal(&cfg); err != nil {
+		cfg.Bird.Instances = nil
+	}
+
+	poolSize := viperx.GetInt("bird.pool_size", 4)
+	timeout := time.Duration(viperx.GetInt("bird.timeout", 30)) * time.Second
+	limits := Limits{
+		MaxBytes:  viperx.GetInt64("bird.max_bytes", 16<<20),
+		MaxRoutes: viperx.GetInt("bird.max_routes", 10000),
+	}
+
+	entries := cfg.Bird.Instances
+	if len(entries) == 0 {
+		entries = []instanceEntry{{
+			Name:   DefaultInstanceName,
+			Socket: viperx.GetString("bird.socket", "/var/run/bird/bird.ctl"),
+		}}
+	}
+
+	for _, ent := range entries {
+		if ent.Name == "" || ent.Socket == "" {
+			continue
+		}
+		size := ent.PoolSize
+		if size == 0 {
+			size = poolSize
+		}
+		pool := NewPool(ent.Socket, size, timeout)
+		pool.limits = limits
+		instances = append(instances, &Instance{
+			Name:   ent.Name,
+			Family: strings.ToLower(ent.Family),
+			pool:   pool,
+		})
+	}
+}
+
+// Instances returns all configured BIRD instances, the default one first
+func Instances() []*Instance {
+	instancesOnce.Do(loadInstances)
+	return instances
+}
+
+// GetInstance looks up an instance by name. An empty name selects the
+// default instance.
+func GetInstance(name string) (*Instance, error) {
+	all := Instances()
+	if len(all) == 0 {
+		return nil, ErrUnknownInstance
+	}
+	if name == "" {
+		return all[0], nil
+	}
+	for _, inst := range all {
+		if inst.Name == name {
+			return inst, nil
+		}
+	}
+	return nil, ErrUnknownInstance
+}
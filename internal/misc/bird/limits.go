@@ -0,0 +1,59 @@
+package bird
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// TruncatedMarker starts the line appended to output that was cut off
+// at one of the Limits
+const TruncatedMarker = "--- output truncated"
+
+var ErrTruncated = errors.New("bird: output truncated")
+
+// Limits bound the output of a single query. Zero disables a limit.
+type Limits struct {
+	MaxBytes  int64
+	MaxRoutes int
+}
+
+// isRouteStart reports whether a line starts a route of a route listing.
+// Next hops are printed with a leading tab and table headers with
+// "Table", everything else under the route code is a route.
+func isRouteStart(line Line) bool {
+	return line.Code == CodeRouteList && !line.Continuation && line.Text != "" &&
+		line.Text[0] != '\t' && !strings.HasPrefix(line.Text, "Table ")
+}
+
+// limitLines wraps a readReply callback so it stops at the limits. The
+// marker line is written in place of the first line over a limit.
+func limitLines(limits Limits, output io.Writer, fn func(Line) error) func(Line) error {
+	var bytes int64
+	var routes int
+
+	truncate := func(reason string) error {
+		_, err := fmt.Fprintf(output, "%s: %s ---\n", TruncatedMarker, reason)
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: %s", ErrTruncated, reason)
+	}
+
+	return func(line Line) error {
+		if isRouteStart(line) {
+			if limits.MaxRoutes > 0 && routes >= limits.MaxRoutes {
+				return truncate(fmt.Sprintf("more than %d routes", limits.MaxRoutes))
+			}
+			routes++
+		}
+
+		n := int64(len(line.Text) + 1)
+		if limits.MaxBytes > 0 && bytes+n > limits.MaxBytes {
+			return truncate(fmt.Sprintf("more than %d bytes", limits.MaxBytes))
+		}
+		bytes += n
+		return fn(line)
+	}
+}
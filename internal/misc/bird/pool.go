@@ -0,0 +1,328 @@
+package bird
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/LaunchPad-Network/NetPeek/constant"
+)
+
+// conn is a control socket session that has already read the greeting
+type conn struct {
+	net.Conn
+	r          *bufio.Reader
+	restricted bool
+	reused     bool
+	lastUsed   time.Time
+}
+
+func (c *conn) next() (string, error) {
+	return c.r.ReadString('\n')
+}
+
+var ErrPoolClosed = errors.New("bird: connection pool closed")
+
+// Pool keeps a bounded number of BIRD control socket connections
+// and reuses them across queries. Restricted sessions cannot be
+// unrestricted again, so they are only handed out to restricted queries.
+type Pool struct {
+	path    string
+	size    int
+	timeout time.Duration
+	limits  Limits
+
+	mu       sync.Mutex
+	open     int
+	idle     []*conn
+	released chan struct{}
+	closed   bool
+}
+
+// NewPool creates a pool of at most size connections to the socket at path.
+// timeout bounds queries whose context carries no deadline.
+func NewPool(path string, size int, timeout time.Duration) *Pool {
+	if size < 1 {
+		size = 1
+	}
+	return &Pool{
+		path:     path,
+		size:     size,
+		timeout:  timeout,
+		released: make(chan struct{}),
+	}
+}
+
+func (p *Pool) dial(ctx context.Context) (*conn, error) {
+	var d net.Dialer
+	nc, err := d.DialContext(ctx, "unix", p.path)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &conn{
+		Conn: nc,
+		r:    bufio.NewReader(nc),
+	}
+	stop := c.watch(ctx, p.timeout)
+	err = handshake(c.next)
+	stop()
+	if err != nil {
+		nc.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// watch applies the context deadline to the connection and interrupts
+// blocked reads when the context is canceled.
+func (c *conn) watch(ctx context.Context, timeout time.Duration) func() {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(timeout)
+	}
+	c.SetDeadline(deadline)
+
+	stop := context.AfterFunc(ctx, func() {
+		c.SetDeadline(time.Unix(1, 0))
+	})
+	return func() {
+		stop()
+		c.SetDeadline(time.Time{})
+	}
+}
+
+// takeIdle pops the most recently used idle connection usable for the query.
+// Must be called with p.mu held.
+func (p *Pool) takeIdle(restricted bool) *conn {
+	for i := len(p.idle) - 1; i >= 0; i-- {
+		c := p.idle[i]
+		if time.Since(c.lastUsed) > constant.BirdConnMaxIdle {
+			p.idle = append(p.idle[:i], p.idle[i+1:]...)
+			p.open--
+			c.Close()
+			continue
+		}
+		if c.restricted && !restricted {
+			continue
+		}
+		p.idle = append(p.idle[:i], p.idle[i+1:]...)
+		return c
+	}
+	return nil
+}
+
+func (p *Pool) acquire(ctx context.Context, restricted bool) (*conn, error) {
+	for {
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			return nil, ErrPoolClosed
+		}
+		if c := p.takeIdle(restricted); c != nil {
+			p.mu.Unlock()
+			c.reused = true
+			return c, nil
+		}
+
+		if p.open >= p.size && len(p.idle) > 0 {
+			// Only sessions in the wrong mode are idle, replace the oldest one
+			p.idle[0].Close()
+			p.idle = p.idle[1:]
+			p.open--
+		}
+
+		if p.open < p.size {
+			p.open++
+			p.mu.Unlock()
+
+			c, err := p.dial(ctx)
+			if err != nil {
+				p.release(nil)
+				return nil, err
+			}
+			return c, nil
+		}
+
+		wait := p.released
+		p.mu.Unlock()
+
+		select {
+		case <-wait:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// release returns a healthy connection to the pool, or frees its slot if
+// c is nil. Connections of a closed pool are closed instead.
+func (p *Pool) release(c *conn) {
+	p.mu.Lock()
+	switch {
+	case c != nil && !p.closed:
+		c.lastUsed = time.Now()
+		p.idle = append(p.idle, c)
+	case c != nil:
+		c.Close()
+		p.open--
+	default:
+		p.open--
+	}
+	close(p.released)
+	p.released = make(chan struct{})
+	p.mu.Unlock()
+}
+
+// discard closes a connection whose session state is unknown
+func (p *Pool) discard(c *conn) {
+	c.Close()
+	p.release(nil)
+}
+
+func (c *conn) query(ctx context.Context, timeout time.Duration, q string, restricted bool, output io.Writer, limits Limits) error {
+	stop := c.watch(ctx, timeout)
+	defer stop()
+
+	if restricted && !c.restricted {
+		if err := restrict(c.next, c); err != nil {
+			return err
+		}
+		c.restricted = true
+	}
+	if f, ok := output.(flusher); ok {
+		output = &idleFlusher{w: output, f: f, r: c.r}
+		defer f.Flush()
+	}
+	return query(c.next, c, q, output, limits)
+}
+
+// flusher is implemented by writers that can push buffered data to the
+// client, such as gin.ResponseWriter
+type flusher interface {
+	Flush()
+}
+
+// idleFlusher flushes the output whenever nothing more is buffered from
+// the socket, so lines reach the client as soon as BIRD sends them
+// without flushing after every single line of a long reply.
+type idleFlusher struct {
+	w io.Writer
+	f flusher
+	r *bufio.Reader
+}
+
+func (fw *idleFlusher) Write(b []byte) (int, error) {
+	n, err := fw.w.Write(b)
+	if err == nil && fw.r.Buffered() == 0 {
+		fw.f.Flush()
+	}
+	return n, err
+}
+
+// countingWriter records whether anything was written to the client
+type countingWriter struct {
+	w io.Writer
+	n int
+}
+
+func (cw *countingWriter) Write(b []byte) (int, error) {
+	n, err := cw.w.Write(b)
+	cw.n += n
+	return n, err
+}
+
+func (cw *countingWriter) Flush() {
+	if f, ok := cw.w.(flusher); ok {
+		f.Flush()
+	}
+}
+
+// reusable reports whether a session is still in a known state after err
+func reusable(err error) bool {
+	if err == nil {
+		return true
+	}
+	var replyErr *ReplyError
+	return errors.As(err, &replyErr) && !errors.Is(err, ErrRestrictNotAccepted)
+}
+
+// stale reports whether err shows that a pooled connection was closed,
+// e.g. because BIRD was restarted. Timeouts are not, the query may have
+// been running.
+func stale(err error) bool {
+	return errors.Is(err, io.EOF) ||
+		errors.Is(err, net.ErrClosed) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, syscall.ECONNRESET)
+}
+
+// ctxErr returns the error of ctx if it ended the query. The socket
+// deadline is the one of ctx, so it may expire before ctx reports it.
+func ctxErr(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if d, ok := ctx.Deadline(); ok && errors.Is(err, os.ErrDeadlineExceeded) && !time.Now().Before(d) {
+		return context.DeadlineExceeded
+	}
+	return nil
+}
+
+// Query runs q on a pooled connection and streams the reply text to output.
+// A connection that went stale, e.g. because BIRD was restarted, is
+// replaced once as long as nothing has been written yet.
+func (p *Pool) Query(ctx context.Context, q string, restricted bool, output io.Writer) error {
+	cw := &countingWriter{w: output}
+	for attempt := 0; ; attempt++ {
+		c, err := p.acquire(ctx, restricted)
+		if err != nil {
+			return err
+		}
+
+		err = c.query(ctx, p.timeout, q, restricted, cw, p.limits)
+		if reusable(err) {
+			if ctx.Err() != nil {
+				// A late cancellation may still move the deadline
+				p.discard(c)
+			} else {
+				p.release(c)
+			}
+			return err
+		}
+		p.discard(c)
+
+		if err := ctxErr(ctx, err); err != nil {
+			return err
+		}
+		if !c.reused || cw.n > 0 || attempt > 0 || !stale(err) {
+			return err
+		}
+	}
+}
+
+// Close closes all idle connections and wakes queries waiting for one,
+// which fail with ErrPoolClosed like all later ones. Connections in use
+// are closed when their query is done.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return
+	}
+	p.closed = true
+	for _, c := range p.idle {
+		c.Close()
+		p.open--
+	}
+	p.idle = nil
+	close(p.released)
+	p.released = make(chan struct{})
+}
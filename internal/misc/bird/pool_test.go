@@ -0,0 +1,224 @@
+package bird
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeBird serves a minimal BIRD control protocol on a unix socket.
+// Every command is answered with a single route line, except "hang"
+// which never gets a reply.
+type fakeBird struct {
+	ln       net.Listener
+	accepted atomic.Int32
+}
+
+func startFakeBird(t *testing.T, path string) *fakeBird {
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fb := &fakeBird{ln: ln}
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			fb.accepted.Add(1)
+			go fb.serve(c)
+		}
+	}()
+	return fb
+}
+
+func (fb *fakeBird) serve(c net.Conn) {
+	defer c.Close()
+	c.Write([]byte("0001 BIRD 2.0.12 ready.\n"))
+	r := bufio.NewReader(c)
+	for {
+		cmd, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		switch strings.TrimSpace(cmd) {
+		case "restrict":
+			c.Write([]byte("0016 Access restricted\n"))
+		case "hang":
+		default:
+			c.Write([]byte("1007-192.0.2.0/24 unicast [bgp1 2024-01-01] * (100)\n0000 \n"))
+		}
+	}
+}
+
+func TestPoolReuse(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bird.ctl")
+	fb := startFakeBird(t, path)
+	defer fb.ln.Close()
+
+	pool := NewPool(path, 2, time.Second)
+	defer pool.Close()
+
+	for i := 0; i < 3; i++ {
+		var out bytes.Buffer
+		if err := pool.Query(context.Background(), "show route", true, &out); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(out.String(), "192.0.2.0/24") {
+			t.Fatalf("unexpected output: %q", out.String())
+		}
+	}
+	if n := fb.accepted.Load(); n != 1 {
+		t.Fatalf("expected 1 connection, got %d", n)
+	}
+}
+
+func TestPoolReconnect(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bird.ctl")
+	fb := startFakeBird(t, path)
+
+	pool := NewPool(path, 1, time.Second)
+	defer pool.Close()
+
+	var out bytes.Buffer
+	if err := pool.Query(context.Background(), "show route", true, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate a daemon restart: the idle connection is now dead
+	fb.ln.Close()
+	pool.mu.Lock()
+	pool.idle[0].Conn.Close()
+	pool.mu.Unlock()
+	fb = startFakeBird(t, path)
+	defer fb.ln.Close()
+
+	out.Reset()
+	if err := pool.Query(context.Background(), "show route", true, &out); err != nil {
+		t.Fatalf("expected reconnect, got %v", err)
+	}
+}
+
+func TestPoolDeadline(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bird.ctl")
+	fb := startFakeBird(t, path)
+	defer fb.ln.Close()
+
+	pool := NewPool(path, 1, time.Second)
+	defer pool.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	var out bytes.Buffer
+	err := pool.Query(ctx, "hang", true, &out)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+
+	// The slot of the hung session must have been freed
+	if err := pool.Query(context.Background(), "show route", true, &out); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPoolNoRetryAfterTimeout(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bird.ctl")
+	fb := startFakeBird(t, path)
+	defer fb.ln.Close()
+
+	pool := NewPool(path, 1, 50*time.Millisecond)
+	defer pool.Close()
+
+	var out bytes.Buffer
+	if err := pool.Query(context.Background(), "show route", true, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	// The hung query runs on the reused connection and must not be sent
+	// again on a new one
+	start := time.Now()
+	if err := pool.Query(context.Background(), "hang", true, &out); err == nil {
+		t.Fatal("expected a timeout")
+	}
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Errorf("query took %v", d)
+	}
+	if n := fb.accepted.Load(); n != 1 {
+		t.Errorf("expected 1 connection, got %d", n)
+	}
+}
+
+// flushRecorder records how much output had been written at each flush
+type flushRecorder struct {
+	bytes.Buffer
+	flushes []int
+}
+
+func (fr *flushRecorder) Flush() {
+	fr.flushes = append(fr.flushes, fr.Len())
+}
+
+func TestPoolFlush(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bird.ctl")
+	fb := startFakeBird(t, path)
+	defer fb.ln.Close()
+
+	pool := NewPool(path, 1, time.Second)
+	defer pool.Close()
+
+	var out flushRecorder
+	if err := pool.Query(context.Background(), "show route", true, &out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out.flushes) == 0 || out.flushes[len(out.flushes)-1] != out.Len() {
+		t.Fatalf("output not flushed: %v of %d bytes", out.flushes, out.Len())
+	}
+}
+
+func TestPoolClose(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bird.ctl")
+	fb := startFakeBird(t, path)
+	defer fb.ln.Close()
+
+	pool := NewPool(path, 1, time.Second)
+	c, err := pool.acquire(context.Background(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A query waiting for the only connection fails once the pool closes
+	waited := make(chan error, 1)
+	go func() {
+		_, err := pool.acquire(context.Background(), true)
+		waited <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	pool.Close()
+
+	select {
+	case err := <-waited:
+		if !errors.Is(err, ErrPoolClosed) {
+			t.Errorf("waiting acquire() = %v, want %v", err, ErrPoolClosed)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("waiting acquire() did not return after Close()")
+	}
+
+	// The connection in use is closed instead of pooled
+	pool.release(c)
+	if pool.open != 0 || len(pool.idle) != 0 {
+		t.Errorf("%d open and %d idle connections after Close()", pool.open, len(pool.idle))
+	}
+	if err := pool.Query(context.Background(), "show route", true, &bytes.Buffer{}); !errors.Is(err, ErrPoolClosed) {
+		t.Errorf("Query() after Close() = %v, want %v", err, ErrPoolClosed)
+	}
+}
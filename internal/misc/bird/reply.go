@@ -0,0 +1,167 @@
+package bird
+
+import (
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Reply codes of the BIRD control protocol referenced by this package.
+// See doc/reply_codes in the BIRD source tree for the full list.
+const (
+	CodeOK               = 0
+	CodeWelcome          = 1
+	CodeStatusReport     = 13
+	CodeAccessRestricted = 16
+	CodeVersion          = 1000
+	CodeProtocolList     = 1002
+	CodeProtocolDetails  = 1006
+	CodeRouteList        = 1007
+	CodeRouteDetails     = 1008
+	CodeUptime           = 1011
+	CodeRouteAttributes  = 1012
+	CodeReplyTooLong     = 8000
+	CodeNoProtocolsMatch = 8003
+	CodeAccessDenied     = 8007
+	CodeParseError       = 9001
+)
+
+// Line is a single line of a BIRD reply
+type Line struct {
+	// Code is the 4-digit reply code. Continuation lines carry the code
+	// of the line they continue.
+	Code int
+	Text string
+	// Continuation is set for lines that started with a space instead of
+	// a reply code
+	Continuation bool
+}
+
+// Reply is a complete BIRD reply to one command
+type Reply struct {
+	Lines  []Line
+	Status Line
+}
+
+// Data groups the text of all non-final lines by reply code
+func (r *Reply) Data() map[int][]string {
+	data := make(map[int][]string)
+	for _, line := range r.Lines {
+		data[line.Code] = append(data[line.Code], line.Text)
+	}
+	return data
+}
+
+// Text returns the reply as plain text without reply codes,
+// the way birdc prints it.
+func (r *Reply) Text() string {
+	var sb strings.Builder
+	for _, line := range r.Lines {
+		sb.WriteString(line.Text)
+		sb.WriteByte('\n')
+	}
+	if r.Status.Text != "" && r.Err() == nil {
+		sb.WriteString(r.Status.Text)
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// Err returns a *ReplyError if the final status reports a failure
+func (r *Reply) Err() error {
+	return r.Status.Err()
+}
+
+// Err returns a *ReplyError if the line is a failed final status
+func (l Line) Err() error {
+	if l.Code < 8000 {
+		return nil
+	}
+	return &ReplyError{
+		Code:    l.Code,
+		Message: l.Text,
+		Kind:    classifyReply(l.Code, l.Text),
+	}
+}
+
+func isNumeric(b byte) bool {
+	return b >= '0' && b <= '9'
+}
+
+// isFinalCode reports whether a code ends a reply when followed by a space.
+// 0xxx are successful completions, 8xxx runtime errors and 9xxx parse errors.
+func isFinalCode(code int) bool {
+	return code < 1000 || code >= 8000
+}
+
+// ParseLine parses one raw line, without its trailing newline.
+// prevCode is the code of the previous line, inherited by continuations.
+// It reports whether the line terminates the reply.
+func ParseLine(raw string, prevCode int) (Line, bool) {
+	raw = strings.TrimRight(raw, "\r\n")
+
+	// Trailing spaces may have been stripped from bare status lines like "0000 "
+	if len(raw) == 4 {
+		raw += " "
+	}
+
+	if len(raw) >= 5 && isNumeric(raw[0]) && isNumeric(raw[1]) && isNumeric(raw[2]) && isNumeric(raw[3]) &&
+		(raw[4] == '-' || raw[4] == ' ') {
+		code, _ := strconv.Atoi(raw[:4])
+		line := Line{
+			Code: code,
+			Text: raw[5:],
+		}
+		return line, raw[4] == ' ' && isFinalCode(code)
+	}
+
+	return Line{
+		Code:         prevCode,
+		Text:         strings.TrimPrefix(raw, " "),
+		Continuation: true,
+	}, false
+}
+
+// lineReader yields raw reply lines one at a time
+type lineReader func() (string, error)
+
+// readReply consumes one reply, handing every non-final line to fn as soon
+// as it is read. It returns the final status line.
+func readReply(next lineReader, fn func(Line) error) (Line, error) {
+	prevCode := -1
+	for {
+		raw, err := next()
+		if raw == "" && err != nil {
+			if err == io.EOF {
+				return Line{}, ErrUnexpectedEOF
+			}
+			return Line{}, err
+		}
+
+		line, final := ParseLine(raw, prevCode)
+		if final {
+			return line, nil
+		}
+		prevCode = line.Code
+
+		if fn != nil {
+			if err := fn(line); err != nil {
+				return Line{}, err
+			}
+		}
+	}
+}
+
+// ReadReply reads and parses one complete reply from r
+func ReadReply(r io.Reader) (*Reply, error) {
+	reply := &Reply{}
+	status, err := readReply(newLineReader(r), func(line Line) error {
+		reply.Lines = append(reply.Lines, line)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	reply.Status = status
+	return reply, nil
+}
@@ -0,0 +1,162 @@
+package bird
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const protocolsReply = `1002-Name       Proto      Table      State  Since         Info
+1002-bgp1       BGP        ---        up     2024-01-01    Established
+1006-  BGP state:          Established
+     Neighbor address: 192.0.2.1
+0000
+`
+
+func TestReadReply(t *testing.T) {
+	reply, err := ReadReply(strings.NewReader(protocolsReply))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply.Err() != nil {
+		t.Fatalf("unexpected reply error: %v", reply.Err())
+	}
+	if reply.Status.Code != CodeOK {
+		t.Fatalf("expected status %d, got %d", CodeOK, reply.Status.Code)
+	}
+	if len(reply.Lines) != 4 {
+		t.Fatalf("expected 4 lines, got %d", len(reply.Lines))
+	}
+
+	cont := reply.Lines[3]
+	if !cont.Continuation || cont.Code != CodeProtocolDetails {
+		t.Fatalf("continuation line not attached to 1006: %+v", cont)
+	}
+
+	data := reply.Data()
+	if len(data[CodeProtocolList]) != 2 || len(data[CodeProtocolDetails]) != 2 {
+		t.Fatalf("unexpected grouping: %v", data)
+	}
+
+	want := "Name       Proto      Table      State  Since         Info\n"
+	if !strings.HasPrefix(reply.Text(), want) {
+		t.Fatalf("unexpected text:\n%s", reply.Text())
+	}
+}
+
+func TestReplyErrors(t *testing.T) {
+	tests := []struct {
+		reply string
+		kind  error
+		class string
+	}{
+		{"9001 syntax error, unexpected CF_SYM_UNDEFINED\n", ErrSyntax, ClassSyntax},
+		{"8003 No protocols match\n", ErrUnknownProtocol, ClassUnknownProtocol},
+		{"8007 Access denied\n", ErrAccessRestricted, ClassAccessRestricted},
+		{"9001 foo is not a table\n", ErrTableNotFound, ClassTableNotFound},
+		{"8001 Network not found\n", ErrRuntime, ClassRuntime},
+	}
+
+	for _, tt := range tests {
+		reply, err := ReadReply(strings.NewReader(tt.reply))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = reply.Err()
+		if !errors.Is(err, tt.kind) {
+			t.Errorf("%q: expected %v, got %v", tt.reply, tt.kind, err)
+			continue
+		}
+
+		var replyErr *ReplyError
+		if !errors.As(err, &replyErr) || replyErr.Class() != tt.class {
+			t.Errorf("%q: expected class %s, got %v", tt.reply, tt.class, err)
+			continue
+		}
+
+		rebuilt := ErrorFromClass(replyErr.Class(), replyErr.Message)
+		if !errors.Is(rebuilt, tt.kind) {
+			t.Errorf("%q: class did not round-trip", tt.reply)
+		}
+	}
+}
+
+func TestReadReplyEOF(t *testing.T) {
+	_, err := ReadReply(strings.NewReader("1007-partial\n"))
+	if !errors.Is(err, ErrUnexpectedEOF) {
+		t.Fatalf("expected ErrUnexpectedEOF, got %v", err)
+	}
+}
+
+func TestRestrictNotAcknowledged(t *testing.T) {
+	var sent bytes.Buffer
+	next := newLineReader(strings.NewReader("0001 BIRD 2.0.12 ready.\n9001 syntax error\n"))
+
+	if err := handshake(next); err != nil {
+		t.Fatal(err)
+	}
+	err := restrict(next, &sent)
+	if !errors.Is(err, ErrRestrictNotAccepted) {
+		t.Fatalf("expected ErrRestrictNotAccepted, got %v", err)
+	}
+	if sent.String() != "restrict\n" {
+		t.Fatalf("unexpected command sent: %q", sent.String())
+	}
+}
+
+func TestQuery(t *testing.T) {
+	var sent, out bytes.Buffer
+	next := newLineReader(strings.NewReader("0016 Access restricted\n" + protocolsReply))
+
+	if err := restrict(next, &sent); err != nil {
+		t.Fatal(err)
+	}
+	if err := query(next, &sent, "show protocols", &out, Limits{}); err != nil {
+		t.Fatal(err)
+	}
+	if sent.String() != "restrict\nshow protocols\n" {
+		t.Fatalf("unexpected commands sent: %q", sent.String())
+	}
+	if strings.Count(out.String(), "\n") != 4 {
+		t.Fatalf("unexpected output:\n%s", out.String())
+	}
+}
+
+const routesReply = `1007-Table master4:
+1007-192.0.2.0/24 unicast [bgp1 2024-01-01] * (100)
+1007-	via 198.51.100.1 on eth0
+1008-	Type: BGP univ
+1007-                     unicast [bgp2 2024-01-01] (100)
+1007-198.51.100.0/24 unicast [bgp1 2024-01-01] * (100)
+1007-203.0.113.0/24 unicast [bgp1 2024-01-01] * (100)
+0000 
+`
+
+func TestQueryLimits(t *testing.T) {
+	var sent, out bytes.Buffer
+	next := newLineReader(strings.NewReader(routesReply))
+
+	err := query(next, &sent, "show route all", &out, Limits{MaxRoutes: 3})
+	if !errors.Is(err, ErrTruncated) {
+		t.Fatalf("expected ErrTruncated, got %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != 7 || !strings.HasPrefix(lines[6], TruncatedMarker) {
+		t.Fatalf("unexpected output:\n%s", out.String())
+	}
+
+	out.Reset()
+	next = newLineReader(strings.NewReader(routesReply))
+	err = query(next, &sent, "show route all", &out, Limits{MaxBytes: 70})
+	if !errors.Is(err, ErrTruncated) || !strings.HasPrefix(out.String(), "Table master4:\n192.0.2.0/24") {
+		t.Fatalf("unexpected result %v:\n%s", err, out.String())
+	}
+}
+
+func TestParseStatus(t *testing.T) {
+	status := ParseStatus("BIRD 2.0.12\nRouter ID is 192.0.2.1\nCurrent server time is 2024-01-01 00:00:00.000\nDaemon is up and running\n")
+	if status.Version != "2.0.12" || status.RouterID != "192.0.2.1" || status.Message != "Daemon is up and running" {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+}
@@ -0,0 +1,50 @@
+package bird
+
+import (
+	"bytes"
+	"context"
+	"strings"
+)
+
+// DaemonStatus is the parsed reply of `show status`
+type DaemonStatus struct {
+	Version  string `json:"version"`
+	RouterID string `json:"router_id"`
+	Message  string `json:"message"`
+}
+
+// ParseStatus parses the text of `show status`, e.g.
+//
+//	BIRD 2.0.12
+//	Router ID is 192.0.2.1
+//	...
+//	Daemon is up and running
+func ParseStatus(text string) *DaemonStatus {
+	status := &DaemonStatus{}
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "BIRD "):
+			status.Version = strings.TrimPrefix(line, "BIRD ")
+		case strings.HasPrefix(line, "Router ID is "):
+			status.RouterID = strings.TrimPrefix(line, "Router ID is ")
+		case strings.HasPrefix(line, "Daemon is "):
+			status.Message = line
+		}
+	}
+	return status
+}
+
+// Status asks the instance for its version and router ID
+func (i *Instance) Status(ctx context.Context) (*DaemonStatus, error) {
+	var out bytes.Buffer
+	if err := i.pool.Query(ctx, "show status", true, &out); err != nil {
+		return nil, err
+	}
+	return ParseStatus(out.String()), nil
+}
+
+// Limits returns the output limits of queries on this instance
+func (i *Instance) Limits() Limits {
+	return i.pool.limits
+}
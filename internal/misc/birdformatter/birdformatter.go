@@ -5,36 +5,73 @@ import (
 	"net/url"
 	"regexp"
 	"strings"
+	"time"
 
+	"github.com/LaunchPad-Network/NetPeek/constant"
 	"github.com/LaunchPad-Network/NetPeek/internal/misc/asnlookup"
 	"github.com/LaunchPad-Network/NetPeek/internal/misc/communityparser"
 	"github.com/LaunchPad-Network/NetPeek/internal/misc/serverslist"
+	"github.com/patrickmn/go-cache"
 )
 
 type SmartFormatterOptions struct {
 	Server          *serverslist.Server
+	Instance        string
 	CurrentProtocol string
 	IsRouteOutput   bool
 }
 
+var (
+	asColumn       = regexp.MustCompile(`(^|\s)AS(\d+)(\s)`)
+	asNumber       = regexp.MustCompile(`(\d+)`)
+	filteredRoutes = regexp.MustCompile(`\b([1-9]\d*)\s+filtered\b`)
+	domainName     = regexp.MustCompile(`([a-zA-Z0-9\-]*\.([a-zA-Z]{2,3}){1,2})(\s|$)`)
+	protocolAS     = regexp.MustCompile(`\[AS(\d+)`)
+	ipv4Addr       = regexp.MustCompile(`(\d+\.\d+\.\d+\.\d+)`)
+	ipv6Addr       = regexp.MustCompile(`(?i)(([a-f\d]{0,4}:){3,10}[a-f\d]{0,4})`)
+)
+
+// asNames caches the names of ASNs, including the fallback of failed
+// lookups, so streamed output does not look the same AS up again
+var asNames = cache.New(constant.ASNameCacheDuration, 10*time.Minute)
+
+// asName returns the name of an AS for the title of its link
+func asName(asn string) string {
+	if v, found := asNames.Get(asn); found {
+		return v.(string)
+	}
+	name := asnlookup.Lookup.Lookup(asn)
+	asNames.Set(asn, name, cache.DefaultExpiration)
+	return name
+}
+
 func SmartFormatter(s string, options SmartFormatterOptions) template.HTML {
 	var result string
 	s = template.HTMLEscapeString(s)
 	for _, line := range strings.Split(s, "\n") {
 		var lineFormatted string
 		if strings.HasPrefix(strings.TrimSpace(line), "Neighbor AS:") || strings.HasPrefix(strings.TrimSpace(line), "Local AS:") {
-			lineFormatted = regexp.MustCompile(`(\d+)`).ReplaceAllString(line, `<a href="/whois?q=AS${1}" class="smart-whois" target="_blank">${1}</a>`)
+			lineFormatted = asNumber.ReplaceAllString(line, `<a href="/whois?q=AS${1}" class="smart-whois" target="_blank">${1}</a>`)
 		} else if strings.HasPrefix(strings.TrimSpace(line), "BGP.as_path:") || strings.HasPrefix(strings.TrimSpace(line), "bgp_path:") {
-			lineFormatted = regexp.MustCompile(`(\d+)`).ReplaceAllStringFunc(line, func(s string) string {
-				return `<a href="/whois?q=AS` + s + `" class="smart-whois" target="_blank"><abbr class="smart-asn" title="` + asnlookup.Lookup.Lookup(s) + `">` + s + `</abbr></a>`
+			lineFormatted = asNumber.ReplaceAllStringFunc(line, func(s string) string {
+				return `<a href="/whois?q=AS` + s + `" class="smart-whois" target="_blank"><abbr class="smart-asn" title="` + asName(s) + `">` + s + `</abbr></a>`
 			})
 		} else if strings.HasPrefix(strings.TrimSpace(line), "Routes:") && options.Server != nil && options.CurrentProtocol != "" {
-			lineFormatted = regexp.MustCompile(`\b([1-9]\d*)\s+filtered\b`).ReplaceAllString(line, `<a href="/detail/`+options.Server.Id+`?mode=filter&q=`+url.QueryEscape(options.CurrentProtocol)+`" class="smart-whois" target="_blank">${1} filtered</a>`)
+			filterUrl := "/detail/" + options.Server.Id + "?mode=filter&q=" + url.QueryEscape(options.CurrentProtocol)
+			if options.Instance != "" {
+				filterUrl += "&instance=" + url.QueryEscape(options.Instance)
+			}
+			lineFormatted = filteredRoutes.ReplaceAllString(line, `<a href="`+template.HTMLEscapeString(filterUrl)+`" class="smart-whois" target="_blank">${1} filtered</a>`)
 		} else {
-			lineFormatted = regexp.MustCompile(`([a-zA-Z0-9\-]*\.([a-zA-Z]{2,3}){1,2})(\s|$)`).ReplaceAllString(line, `<a href="/whois?q=${1}" class="smart-whois" target="_blank">${1}</a>${3}`)
-			lineFormatted = regexp.MustCompile(`\[AS(\d+)`).ReplaceAllString(lineFormatted, `[<a href="/whois?q=AS${1}" class="smart-whois" target="_blank">AS${1}</a>`)
-			lineFormatted = regexp.MustCompile(`(\d+\.\d+\.\d+\.\d+)`).ReplaceAllString(lineFormatted, `<a href="/whois?q=${1}" class="smart-whois" target="_blank">${1}</a>`)
-			lineFormatted = regexp.MustCompile(`(?i)(([a-f\d]{0,4}:){3,10}[a-f\d]{0,4})`).ReplaceAllString(lineFormatted, `<a href="/whois?q=${1}" class="smart-whois" target="_blank">${1}</a>`)
+			lineFormatted = domainName.ReplaceAllString(line, `<a href="/whois?q=${1}" class="smart-whois" target="_blank">${1}</a>${3}`)
+			lineFormatted = protocolAS.ReplaceAllString(lineFormatted, `[<a href="/whois?q=AS${1}" class="smart-whois" target="_blank">AS${1}</a>`)
+			// AS column of traceroute output
+			lineFormatted = asColumn.ReplaceAllStringFunc(lineFormatted, func(s string) string {
+				m := asColumn.FindStringSubmatch(s)
+				return m[1] + `<a href="/whois?q=AS` + m[2] + `" class="smart-whois" target="_blank"><abbr class="smart-asn" title="` + template.HTMLEscapeString(asName(m[2])) + `">AS` + m[2] + `</abbr></a>` + m[3]
+			})
+			lineFormatted = ipv4Addr.ReplaceAllString(lineFormatted, `<a href="/whois?q=${1}" class="smart-whois" target="_blank">${1}</a>`)
+			lineFormatted = ipv6Addr.ReplaceAllString(lineFormatted, `<a href="/whois?q=${1}" class="smart-whois" target="_blank">${1}</a>`)
 		}
 		result += lineFormatted + "\n"
 	}
@@ -0,0 +1,105 @@
+package capabilities
+
+// Tools a proxy can offer. They match the query modes of the frontend.
+const (
+	ToolSummary     = "summary"
+	ToolProtocol    = "protocol"
+	ToolRoute       = "route"
+	ToolFilter      = "filter"
+	ToolTraceroute  = "traceroute"
+	ToolTraceroute4 = "traceroute4"
+	ToolTraceroute6 = "traceroute6"
+	ToolPing        = "ping"
+)
+
+// InstanceStatus tells whether a BIRD instance is reachable and what it runs
+type InstanceStatus struct {
+	Name     string `json:"name"`
+	Family   string `json:"family,omitempty"`
+	Up       bool   `json:"up"`
+	Version  string `json:"version,omitempty"`
+	RouterID string `json:"router_id,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// BirdLimits bound the output of a single BIRD query. Zero means unlimited.
+type BirdLimits struct {
+	MaxBytes  int64 `json:"max_bytes"`
+	MaxRoutes int   `json:"max_routes"`
+}
+
+// TracerouteLimits are the probing settings of the proxy's traceroute.
+// Requests may ask for up to MaxHops hops and MaxCount probes per hop.
+type TracerouteLimits struct {
+	MaxHops   int      `json:"max_hops"`
+	Count     int      `json:"count"`
+	MaxCount  int      `json:"max_count"`
+	Timeout   int      `json:"timeout"`
+	Size      int      `json:"size"`
+	Protocols []string `json:"protocols,omitempty"`
+}
+
+// PingLimits bound the settings a ping request may ask for
+type PingLimits struct {
+	MaxCount int `json:"max_count"`
+	// MinInterval is in milliseconds
+	MinInterval int `json:"min_interval"`
+	MaxSize     int `json:"max_size"`
+}
+
+// Source is a source profile traceroutes and pings can send probes from,
+// with its addresses
+type Source struct {
+	Name string `json:"name"`
+	IPv4 string `json:"ipv4,omitempty"`
+	IPv6 string `json:"ipv6,omitempty"`
+}
+
+// SchedulerStats are the limits and current load of the proxy's
+// measurement scheduler. MaxWait is in seconds, Rejections counts jobs
+// that were not run by reason, e.g. "queue_full".
+type SchedulerStats struct {
+	MaxJobs       int               `json:"max_jobs"`
+	MaxJobsPerKey int               `json:"max_jobs_per_key"`
+	MaxQueue      int               `json:"max_queue"`
+	MaxWait       int               `json:"max_wait"`
+	Running       int               `json:"running"`
+	Queued        int               `json:"queued"`
+	Rejections    map[string]uint64 `json:"rejections,omitempty"`
+}
+
+// Capabilities is what a proxy reports on /capabilities
+type Capabilities struct {
+	Version    string            `json:"version"`
+	BuildTime  string            `json:"build_time"`
+	Instances  []InstanceStatus  `json:"instances"`
+	Tools      []string          `json:"tools"`
+	Bird       BirdLimits        `json:"bird"`
+	Traceroute *TracerouteLimits `json:"traceroute,omitempty"`
+	Ping       *PingLimits       `json:"ping,omitempty"`
+	Sources    []Source          `json:"sources,omitempty"`
+	Scheduler  *SchedulerStats   `json:"scheduler,omitempty"`
+	// Rejections counts refused requests by reason, e.g. "replayed"
+	Rejections map[string]uint64 `json:"rejections,omitempty"`
+}
+
+// Supports reports whether the proxy offers a tool
+func (c *Capabilities) Supports(tool string) bool {
+	for _, t := range c.Tools {
+		if t == tool {
+			return true
+		}
+	}
+	return false
+}
+
+// Instance returns the status of the named instance, or of the default
+// one for an empty name
+func (c *Capabilities) Instance(name string) *InstanceStatus {
+	for i := range c.Instances {
+		if name == "" || c.Instances[i].Name == name {
+			return &c.Instances[i]
+		}
+	}
+	return nil
+}
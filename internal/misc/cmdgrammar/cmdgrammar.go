@@ -0,0 +1,258 @@
+package cmdgrammar
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/validator"
+)
+
+var (
+	ErrUnterminatedQuote = errors.New("unterminated quote")
+	ErrUnexpectedQuote   = errors.New("unexpected quote")
+	ErrEmptyCommand      = errors.New("empty command")
+	ErrControlCharacter  = errors.New("control character in command")
+)
+
+// DefaultPatterns are the commands the frontend sends to BIRD
+var DefaultPatterns = []string{
+	"show protocols",
+	"show protocols [all] '<name>'",
+	"show route for <ip|cidr> [all]",
+	"show route filtered [all] protocol '<name>'",
+}
+
+// Argument types usable in placeholders
+var argTypes = map[string]func(string) bool{
+	"ip": func(s string) bool {
+		return net.ParseIP(s) != nil
+	},
+	"cidr": func(s string) bool {
+		_, _, err := net.ParseCIDR(s)
+		return err == nil
+	},
+	"name": validator.IsValidProtocol,
+	"int": func(s string) bool {
+		if s == "" {
+			return false
+		}
+		for i := 0; i < len(s); i++ {
+			if s[i] < '0' || s[i] > '9' {
+				return false
+			}
+		}
+		return true
+	},
+}
+
+type elementKind int
+
+const (
+	kindLiteral elementKind = iota
+	kindArgument
+	kindOptional
+)
+
+type element struct {
+	kind     elementKind
+	literal  string
+	types    []string
+	quoted   bool
+	children []element
+}
+
+// Rule is one compiled command pattern, e.g. "show route for <ip|cidr> [all]".
+// Words are matched case-insensitively, <a|b> takes one argument of any of
+// the listed types, '<...>' requires it in single quotes and [...] marks
+// an optional group.
+type Rule struct {
+	Pattern  string
+	elements []element
+}
+
+// Grammar is a set of allowed commands
+type Grammar struct {
+	rules []*Rule
+}
+
+type token struct {
+	text   string
+	quoted bool
+}
+
+// tokenize splits a query into words and single-quoted strings
+func tokenize(q string) ([]token, error) {
+	for i := 0; i < len(q); i++ {
+		if (q[i] < 0x20 && q[i] != '\t') || q[i] == 0x7f {
+			return nil, ErrControlCharacter
+		}
+	}
+
+	var tokens []token
+	for i := 0; i < len(q); {
+		switch c := q[i]; {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '\'':
+			end := strings.IndexByte(q[i+1:], '\'')
+			if end < 0 {
+				return nil, ErrUnterminatedQuote
+			}
+			tokens = append(tokens, token{text: q[i+1 : i+1+end], quoted: true})
+			i += end + 2
+		default:
+			end := strings.IndexAny(q[i:], " \t")
+			if end < 0 {
+				end = len(q) - i
+			}
+			word := q[i : i+end]
+			if strings.ContainsAny(word, `'"`) {
+				return nil, ErrUnexpectedQuote
+			}
+			tokens = append(tokens, token{text: word})
+			i += end
+		}
+	}
+	return tokens, nil
+}
+
+func parseArgument(word string) (element, error) {
+	el := element{kind: kindArgument}
+	if strings.HasPrefix(word, "'") && strings.HasSuffix(word, "'") && len(word) > 1 {
+		el.quoted = true
+		word = word[1 : len(word)-1]
+	}
+	if !strings.HasPrefix(word, "<") || !strings.HasSuffix(word, ">") {
+		return el, fmt.Errorf("malformed placeholder %q", word)
+	}
+	for _, t := range strings.Split(word[1:len(word)-1], "|") {
+		if _, ok := argTypes[t]; !ok {
+			return el, fmt.Errorf("unknown argument type %q", t)
+		}
+		el.types = append(el.types, t)
+	}
+	return el, nil
+}
+
+func parsePattern(words []string, depth int) ([]element, []string, error) {
+	var elements []element
+	for len(words) > 0 {
+		word := words[0]
+		words = words[1:]
+
+		switch {
+		case word == "[":
+			children, rest, err := parsePattern(words, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			elements = append(elements, element{kind: kindOptional, children: children})
+			words = rest
+		case word == "]":
+			if depth == 0 {
+				return nil, nil, errors.New("unbalanced ]")
+			}
+			return elements, words, nil
+		case strings.Contains(word, "<"):
+			el, err := parseArgument(word)
+			if err != nil {
+				return nil, nil, err
+			}
+			elements = append(elements, el)
+		default:
+			elements = append(elements, element{kind: kindLiteral, literal: word})
+		}
+	}
+	if depth > 0 {
+		return nil, nil, errors.New("unbalanced [")
+	}
+	return elements, nil, nil
+}
+
+// CompileRule compiles a single command pattern
+func CompileRule(pattern string) (*Rule, error) {
+	spaced := strings.NewReplacer("[", " [ ", "]", " ] ").Replace(pattern)
+	elements, _, err := parsePattern(strings.Fields(spaced), 0)
+	if err != nil {
+		return nil, fmt.Errorf("pattern %q: %w", pattern, err)
+	}
+	if len(elements) == 0 {
+		return nil, fmt.Errorf("pattern %q: %w", pattern, ErrEmptyCommand)
+	}
+	return &Rule{
+		Pattern:  pattern,
+		elements: elements,
+	}, nil
+}
+
+// Compile compiles a list of command patterns into a grammar
+func Compile(patterns []string) (*Grammar, error) {
+	g := &Grammar{}
+	for _, pattern := range patterns {
+		rule, err := CompileRule(pattern)
+		if err != nil {
+			return nil, err
+		}
+		g.rules = append(g.rules, rule)
+	}
+	return g, nil
+}
+
+func matchArgument(el element, tok token) bool {
+	if el.quoted != tok.quoted {
+		return false
+	}
+	for _, t := range el.types {
+		if argTypes[t](tok.text) {
+			return true
+		}
+	}
+	return false
+}
+
+// match reports whether elements followed by rest consume all tokens
+func match(elements []element, rest [][]element, tokens []token) bool {
+	if len(elements) == 0 {
+		if len(rest) == 0 {
+			return len(tokens) == 0
+		}
+		return match(rest[0], rest[1:], tokens)
+	}
+
+	el := elements[0]
+	switch el.kind {
+	case kindOptional:
+		withGroup := append([][]element{elements[1:]}, rest...)
+		return match(el.children, withGroup, tokens) || match(elements[1:], rest, tokens)
+	case kindArgument:
+		return len(tokens) > 0 && matchArgument(el, tokens[0]) && match(elements[1:], rest, tokens[1:])
+	default:
+		return len(tokens) > 0 && !tokens[0].quoted && strings.EqualFold(el.literal, tokens[0].text) &&
+			match(elements[1:], rest, tokens[1:])
+	}
+}
+
+// Match checks a query against the rule
+func (r *Rule) Match(q string) bool {
+	tokens, err := tokenize(q)
+	if err != nil {
+		return false
+	}
+	return match(r.elements, nil, tokens)
+}
+
+// Match returns the first rule accepting the query
+func (g *Grammar) Match(q string) (*Rule, bool) {
+	tokens, err := tokenize(q)
+	if err != nil || len(tokens) == 0 {
+		return nil, false
+	}
+	for _, rule := range g.rules {
+		if match(rule.elements, nil, tokens) {
+			return rule, true
+		}
+	}
+	return nil, false
+}
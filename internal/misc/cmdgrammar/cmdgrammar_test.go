@@ -0,0 +1,75 @@
+package cmdgrammar
+
+import "testing"
+
+func TestDefaultGrammar(t *testing.T) {
+	g, err := Compile(DefaultPatterns)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query   string
+		allowed bool
+	}{
+		{"show protocols", true},
+		{"show protocols all 'bgp_peer1'", true},
+		{"show protocols 'bgp_peer1'", true},
+		{"show protocols all bgp_peer1", false},
+		{"show route for 192.0.2.1 all", true},
+		{"show route for 2001:db8::/32", true},
+		{"SHOW ROUTE FOR 192.0.2.0/24 ALL", true},
+		{"show route for example.com all", false},
+		{"show route filtered all protocol 'bgp_peer1'", true},
+		{"show route filtered protocol 'bgp_peer1'", true},
+		{"show route filtered all protocol 'bgp peer'", false},
+		{"show route all", false},
+		{"show route for 192.0.2.1 all all", false},
+		{"configure", false},
+		{"show protocols all 'x'\nconfigure", false},
+		{"show protocols all 'unterminated", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		_, ok := g.Match(tt.query)
+		if ok != tt.allowed {
+			t.Errorf("%q: expected allowed=%v, got %v", tt.query, tt.allowed, ok)
+		}
+	}
+}
+
+func TestNestedOptional(t *testing.T) {
+	rule, err := CompileRule("show route [for <ip> [all]] [table <name>]")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, q := range []string{
+		"show route",
+		"show route for 192.0.2.1",
+		"show route for 192.0.2.1 all table master4",
+		"show route table master4",
+	} {
+		if !rule.Match(q) {
+			t.Errorf("%q should match", q)
+		}
+	}
+	if rule.Match("show route all") {
+		t.Error("nested optional matched without its parent")
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	for _, pattern := range []string{
+		"",
+		"show route [all",
+		"show route all]",
+		"show route for <hostname>",
+		"show route for <ip",
+	} {
+		if _, err := CompileRule(pattern); err == nil {
+			t.Errorf("%q should not compile", pattern)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
+	"crypto/sha256"
 	"crypto/x509"
 	"encoding/base64"
 	"encoding/hex"
@@ -15,6 +16,9 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// Algorithm is the name of ECDSA signatures over P-256 and SHA-256
+const Algorithm = "ecdsa-p256-sha256"
+
 func GenerateKey() (*ecdsa.PrivateKey, error) {
 	return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
 }
@@ -59,16 +63,49 @@ func ImportPublicKeyHex(hexText string) (*ecdsa.PublicKey, error) {
 	return key, nil
 }
 
-func Sign(key *ecdsa.PrivateKey, msg []byte) string {
-	r, s, _ := ecdsa.Sign(rand.Reader, key, msg)
-	sig, _ := json.Marshal(gin.H{
+// Sign signs the SHA-256 digest of msg and returns the base64 encoded
+// ASN.1 DER signature
+func Sign(key *ecdsa.PrivateKey, msg []byte) (string, error) {
+	digest := sha256.Sum256(msg)
+	sig, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(sig), nil
+}
+
+// Verify checks a signature made by Sign
+func Verify(pub *ecdsa.PublicKey, msg []byte, sigText string) bool {
+	sig, err := base64.StdEncoding.DecodeString(sigText)
+	if err != nil {
+		return false
+	}
+	digest := sha256.Sum256(msg)
+	return ecdsa.VerifyASN1(pub, digest[:], sig)
+}
+
+// SignLegacy signs msg the way frontends did before DER signatures: R and
+// S as base64 JSON over the unhashed message. It is only kept for tests.
+func SignLegacy(key *ecdsa.PrivateKey, msg []byte) (string, error) {
+	r, s, err := ecdsa.Sign(rand.Reader, key, msg)
+	if err != nil {
+		return "", err
+	}
+	sig, err := json.Marshal(gin.H{
 		"R": r,
 		"S": s,
 	})
-	return base64.StdEncoding.EncodeToString(sig)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(sig), nil
 }
 
-func Verify(pub *ecdsa.PublicKey, msg []byte, sigText string) bool {
+// VerifyLegacy checks a signature of a frontend from before DER
+// signatures. The message was not hashed, and ECDSA only uses as many
+// bytes of it as the curve order has, so the signature covers the first
+// 32 bytes of msg and nothing after them.
+func VerifyLegacy(pub *ecdsa.PublicKey, msg []byte, sigText string) bool {
 	sigBytes, err := base64.StdEncoding.DecodeString(sigText)
 	if err != nil {
 		return false
@@ -90,10 +127,28 @@ func Verify(pub *ecdsa.PublicKey, msg []byte, sigText string) bool {
 	return ecdsa.Verify(pub, msg, rInt, sInt)
 }
 
-func SignText(key *ecdsa.PrivateKey, text string) string {
-	return Sign(key, []byte(text))
+// Signer signs with an ECDSA private key
+type Signer struct {
+	Key *ecdsa.PrivateKey
+}
+
+func (s *Signer) Algorithm() string {
+	return Algorithm
+}
+
+func (s *Signer) Sign(msg []byte) (string, error) {
+	return Sign(s.Key, msg)
+}
+
+// Verifier checks signatures of an ECDSA public key
+type Verifier struct {
+	Key *ecdsa.PublicKey
+}
+
+func (v *Verifier) Algorithm() string {
+	return Algorithm
 }
 
-func VerifyText(pub *ecdsa.PublicKey, text string, sigText string) bool {
-	return Verify(pub, []byte(text), sigText)
+func (v *Verifier) Verify(msg []byte, sigText string) bool {
+	return Verify(v.Key, msg, sigText)
 }
@@ -0,0 +1,97 @@
+package ed25519
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"log"
+)
+
+// Algorithm is the name of Ed25519 signatures
+const Algorithm = "ed25519"
+
+func GenerateKey() (ed25519.PrivateKey, error) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	return priv, err
+}
+
+func ExportPrivateKeyHex(key ed25519.PrivateKey) string {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return hex.EncodeToString(der)
+}
+
+func ImportPrivateKeyHex(hexText string) (ed25519.PrivateKey, error) {
+	der, err := hex.DecodeString(hexText)
+	if err != nil {
+		return nil, err
+	}
+	priv, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := priv.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("not Ed25519 private key")
+	}
+	return key, nil
+}
+
+func ExportPublicKeyHex(pub ed25519.PublicKey) string {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return hex.EncodeToString(der)
+}
+
+func ImportPublicKeyHex(hexText string) (ed25519.PublicKey, error) {
+	der, err := hex.DecodeString(hexText)
+	if err != nil {
+		return nil, err
+	}
+	pubKey, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := pubKey.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("not Ed25519 public key")
+	}
+	return key, nil
+}
+
+// Signer signs with an Ed25519 private key
+type Signer struct {
+	Key ed25519.PrivateKey
+}
+
+func (s *Signer) Algorithm() string {
+	return Algorithm
+}
+
+func (s *Signer) Sign(msg []byte) (string, error) {
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(s.Key, msg)), nil
+}
+
+// Verifier checks signatures of an Ed25519 public key
+type Verifier struct {
+	Key ed25519.PublicKey
+}
+
+func (v *Verifier) Algorithm() string {
+	return Algorithm
+}
+
+func (v *Verifier) Verify(msg []byte, sigText string) bool {
+	sig, err := base64.StdEncoding.DecodeString(sigText)
+	if err != nil {
+		return false
+	}
+	return ed25519.Verify(v.Key, msg, sig)
+}
@@ -0,0 +1,67 @@
+package hmac
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+)
+
+// Algorithm is the name of HMAC-SHA256 signatures
+const Algorithm = "hmac-sha256"
+
+// minKeySize is the shortest secret accepted, in bytes
+const minKeySize = 16
+
+// GenerateKey returns a random secret of 32 bytes
+func GenerateKey() ([]byte, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func ExportKeyHex(key []byte) string {
+	return hex.EncodeToString(key)
+}
+
+func ImportKeyHex(hexText string) ([]byte, error) {
+	key, err := hex.DecodeString(hexText)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) < minKeySize {
+		return nil, fmt.Errorf("HMAC key shorter than %d bytes", minKeySize)
+	}
+	return key, nil
+}
+
+func sum(key, msg []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(msg)
+	return mac.Sum(nil)
+}
+
+// Signer and Verifier share the secret, so one type is both
+type Signer struct {
+	Key []byte
+}
+
+func (s *Signer) Algorithm() string {
+	return Algorithm
+}
+
+func (s *Signer) Sign(msg []byte) (string, error) {
+	return base64.StdEncoding.EncodeToString(sum(s.Key, msg)), nil
+}
+
+func (s *Signer) Verify(msg []byte, sigText string) bool {
+	sig, err := base64.StdEncoding.DecodeString(sigText)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(sum(s.Key, msg), sig)
+}
@@ -0,0 +1,100 @@
+package signature
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/crypto/ecdsa"
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/crypto/hmac"
+)
+
+// Key file formats
+const (
+	FormatPEM = "pem"
+	FormatHex = "hex"
+)
+
+// pemType returns the PEM block type of a hex encoded key
+func pemType(alg string, private bool) (string, error) {
+	switch {
+	case alg == hmac.Algorithm:
+		return "HMAC SECRET", nil
+	case !private:
+		return "PUBLIC KEY", nil
+	case alg == ecdsa.Algorithm:
+		return "EC PRIVATE KEY", nil
+	}
+	for _, a := range Algorithms {
+		if a == alg {
+			return "PRIVATE KEY", nil
+		}
+	}
+	return "", unsupported(alg)
+}
+
+// EncodeKey encodes a hex key for a key file in the given format
+func EncodeKey(alg, keyHex string, private bool, format string) ([]byte, error) {
+	switch format {
+	case FormatHex:
+		return []byte(keyHex + "\n"), nil
+	case FormatPEM:
+		typ, err := pemType(alg, private)
+		if err != nil {
+			return nil, err
+		}
+		der, err := hex.DecodeString(keyHex)
+		if err != nil {
+			return nil, err
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), nil
+	}
+	return nil, fmt.Errorf("unknown key format %q", format)
+}
+
+// DecodeKey returns the hex encoding of a key in either format
+func DecodeKey(data []byte) (string, error) {
+	data = bytes.TrimSpace(data)
+	if !bytes.HasPrefix(data, []byte("-----BEGIN")) {
+		return strings.ToLower(string(data)), nil
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return "", fmt.Errorf("invalid PEM data")
+	}
+	return hex.EncodeToString(block.Bytes), nil
+}
+
+// ReadKeyFile reads a PEM or hex key file
+func ReadKeyFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	keyHex, err := DecodeKey(data)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", path, err)
+	}
+	return keyHex, nil
+}
+
+// WriteKeyFile creates a key file, refusing to overwrite an existing one.
+// Private keys are only readable by the owner.
+func WriteKeyFile(path string, data []byte, private bool) error {
+	perm := os.FileMode(0o644)
+	if private {
+		perm = 0o600
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
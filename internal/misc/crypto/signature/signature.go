@@ -0,0 +1,134 @@
+package signature
+
+import (
+	stded25519 "crypto/ed25519"
+	"fmt"
+
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/crypto/ecdsa"
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/crypto/ed25519"
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/crypto/hmac"
+)
+
+// Default is the algorithm used unless configured otherwise
+const Default = ecdsa.Algorithm
+
+// Algorithms lists the supported signature algorithms
+var Algorithms = []string{ecdsa.Algorithm, ed25519.Algorithm, hmac.Algorithm}
+
+// Signer signs messages, returning the base64 encoded signature
+type Signer interface {
+	Algorithm() string
+	Sign(msg []byte) (string, error)
+}
+
+// Verifier checks base64 encoded signatures
+type Verifier interface {
+	Algorithm() string
+	Verify(msg []byte, sig string) bool
+}
+
+func unsupported(alg string) error {
+	return fmt.Errorf("unsupported signature algorithm %q", alg)
+}
+
+// NewSigner loads a hex encoded private key, or secret for HMAC
+func NewSigner(alg, keyHex string) (Signer, error) {
+	switch alg {
+	case ecdsa.Algorithm:
+		key, err := ecdsa.ImportPrivateKeyHex(keyHex)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.Signer{Key: key}, nil
+	case ed25519.Algorithm:
+		key, err := ed25519.ImportPrivateKeyHex(keyHex)
+		if err != nil {
+			return nil, err
+		}
+		return &ed25519.Signer{Key: key}, nil
+	case hmac.Algorithm:
+		key, err := hmac.ImportKeyHex(keyHex)
+		if err != nil {
+			return nil, err
+		}
+		return &hmac.Signer{Key: key}, nil
+	}
+	return nil, unsupported(alg)
+}
+
+// NewVerifier loads a hex encoded public key, or secret for HMAC
+func NewVerifier(alg, keyHex string) (Verifier, error) {
+	switch alg {
+	case ecdsa.Algorithm:
+		key, err := ecdsa.ImportPublicKeyHex(keyHex)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.Verifier{Key: key}, nil
+	case ed25519.Algorithm:
+		key, err := ed25519.ImportPublicKeyHex(keyHex)
+		if err != nil {
+			return nil, err
+		}
+		return &ed25519.Verifier{Key: key}, nil
+	case hmac.Algorithm:
+		key, err := hmac.ImportKeyHex(keyHex)
+		if err != nil {
+			return nil, err
+		}
+		return &hmac.Signer{Key: key}, nil
+	}
+	return nil, unsupported(alg)
+}
+
+// GenerateKey creates a hex encoded key pair. Both are the same secret
+// for HMAC.
+func GenerateKey(alg string) (privHex, pubHex string, err error) {
+	switch alg {
+	case ecdsa.Algorithm:
+		key, err := ecdsa.GenerateKey()
+		if err != nil {
+			return "", "", err
+		}
+		return ecdsa.ExportPrivateKeyHex(key), ecdsa.ExportPublicKeyHex(&key.PublicKey), nil
+	case ed25519.Algorithm:
+		key, err := ed25519.GenerateKey()
+		if err != nil {
+			return "", "", err
+		}
+		return ed25519.ExportPrivateKeyHex(key), ed25519.ExportPublicKeyHex(key.Public().(stded25519.PublicKey)), nil
+	case hmac.Algorithm:
+		key, err := hmac.GenerateKey()
+		if err != nil {
+			return "", "", err
+		}
+		secret := hmac.ExportKeyHex(key)
+		return secret, secret, nil
+	}
+	return "", "", unsupported(alg)
+}
+
+// PublicKeyHex derives the hex encoded public key of a private key, which
+// is the secret itself for HMAC
+func PublicKeyHex(alg, privHex string) (string, error) {
+	switch alg {
+	case ecdsa.Algorithm:
+		key, err := ecdsa.ImportPrivateKeyHex(privHex)
+		if err != nil {
+			return "", err
+		}
+		return ecdsa.ExportPublicKeyHex(&key.PublicKey), nil
+	case ed25519.Algorithm:
+		key, err := ed25519.ImportPrivateKeyHex(privHex)
+		if err != nil {
+			return "", err
+		}
+		return ed25519.ExportPublicKeyHex(key.Public().(stded25519.PublicKey)), nil
+	case hmac.Algorithm:
+		if _, err := hmac.ImportKeyHex(privHex); err != nil {
+			return "", err
+		}
+		return privHex, nil
+	}
+	return "", unsupported(alg)
+}
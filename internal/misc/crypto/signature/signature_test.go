@@ -0,0 +1,100 @@
+package signature
+
+import (
+	"testing"
+
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/crypto/ecdsa"
+)
+
+func TestSignVerify(t *testing.T) {
+	msg := []byte("netpeek-proxy-v2\nGET\nbird\nnode1\nq=show+protocols")
+
+	for _, alg := range Algorithms {
+		privHex, pubHex, err := GenerateKey(alg)
+		if err != nil {
+			t.Fatalf("%s: %v", alg, err)
+		}
+		derived, err := PublicKeyHex(alg, privHex)
+		if err != nil || derived != pubHex {
+			t.Errorf("%s: PublicKeyHex = %q, %v, want %q", alg, derived, err, pubHex)
+		}
+
+		signer, err := NewSigner(alg, privHex)
+		if err != nil {
+			t.Fatalf("%s: %v", alg, err)
+		}
+		verifier, err := NewVerifier(alg, pubHex)
+		if err != nil {
+			t.Fatalf("%s: %v", alg, err)
+		}
+
+		sig, err := signer.Sign(msg)
+		if err != nil {
+			t.Fatalf("%s: %v", alg, err)
+		}
+		if !verifier.Verify(msg, sig) {
+			t.Errorf("%s: valid signature rejected", alg)
+		}
+		if verifier.Verify(append(msg, 'x'), sig) {
+			t.Errorf("%s: signature of another message accepted", alg)
+		}
+		if verifier.Verify(msg, "not base64!") {
+			t.Errorf("%s: garbage signature accepted", alg)
+		}
+	}
+}
+
+func TestLegacyECDSA(t *testing.T) {
+	msg := []byte("q=show protocols,ts=1700000000")
+	key, err := ecdsa.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := ecdsa.SignLegacy(key, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !ecdsa.VerifyLegacy(&key.PublicKey, msg, sig) {
+		t.Error("legacy signature rejected by VerifyLegacy")
+	}
+	if ecdsa.VerifyLegacy(&key.PublicKey, []byte("q=show protocols,ts=1700000001"), sig) {
+		t.Error("legacy signature accepted for another message")
+	}
+
+	// Verifiers of the current format never accept the JSON encoding
+	verifier, _ := NewVerifier(ecdsa.Algorithm, ecdsa.ExportPublicKeyHex(&key.PublicKey))
+	if verifier.Verify(msg, sig) {
+		t.Error("legacy signature accepted by the verifier")
+	}
+}
+
+func TestUnsupported(t *testing.T) {
+	if _, err := NewSigner("rsa", ""); err == nil {
+		t.Error("unsupported algorithm accepted")
+	}
+}
+
+func TestKeyEncoding(t *testing.T) {
+	for _, alg := range Algorithms {
+		privHex, pubHex, err := GenerateKey(alg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, key := range []struct {
+			hex     string
+			private bool
+		}{{privHex, true}, {pubHex, false}} {
+			for _, format := range []string{FormatPEM, FormatHex} {
+				data, err := EncodeKey(alg, key.hex, key.private, format)
+				if err != nil {
+					t.Fatalf("%s %s: %v", alg, format, err)
+				}
+				got, err := DecodeKey(data)
+				if err != nil || got != key.hex {
+					t.Errorf("%s %s: DecodeKey = %q, %v, want %q", alg, format, got, err, key.hex)
+				}
+			}
+		}
+	}
+}
@@ -1,6 +1,9 @@
 package net
 
 import (
+	"bytes"
+	"context"
+	"crypto/tls"
 	"io"
 	"net"
 	"net/http"
@@ -11,13 +14,14 @@ import (
 
 var log = logger.New("Net")
 
-func createConnectionTimeoutRoundTripper(timeout int) http.RoundTripper {
+func createConnectionTimeoutRoundTripper(timeout int, tlsConfig *tls.Config) http.RoundTripper {
 	context := net.Dialer{
 		Timeout: time.Duration(timeout) * time.Second,
 	}
 
 	return &http.Transport{
-		DialContext: context.DialContext,
+		DialContext:     context.DialContext,
+		TLSClientConfig: tlsConfig,
 
 		Proxy:                 http.ProxyFromEnvironment,
 		ForceAttemptHTTP2:     true,
@@ -32,12 +36,44 @@ func FetchURLWithTimeout(url string, timeout int) (*http.Response, error) {
 	log.Debugf("Fetching URL: %s", url)
 
 	client := &http.Client{
-		Transport: createConnectionTimeoutRoundTripper(timeout),
+		Transport: createConnectionTimeoutRoundTripper(timeout, nil),
 	}
 
 	return client.Get(url)
 }
 
+// NewClient returns a client connecting with the given dial timeout in
+// seconds and TLS configuration. It keeps connections open for reuse.
+func NewClient(timeout int, tlsConfig *tls.Config) *http.Client {
+	return &http.Client{
+		Transport: createConnectionTimeoutRoundTripper(timeout, tlsConfig),
+	}
+}
+
+// FetchURLWithClient fetches url with client. The request is cancelled
+// together with ctx and the body can be read as it arrives.
+func FetchURLWithClient(ctx context.Context, client *http.Client, url string) (*http.Response, error) {
+	log.Debugf("Fetching URL: %s", url)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return client.Do(req)
+}
+
+// PostURLWithClient is like FetchURLWithClient, but posts body
+func PostURLWithClient(ctx context.Context, client *http.Client, url, contentType string, body []byte) (*http.Response, error) {
+	log.Debugf("Posting to URL: %s", url)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	return client.Do(req)
+}
+
 func FetchURLWithTimeoutAsPlaintext(url string, timeout int) (string, error) {
 	resp, err := FetchURLWithTimeout(url, timeout)
 	if err != nil {
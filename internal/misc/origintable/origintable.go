@@ -0,0 +1,82 @@
+package origintable
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Table maps prefixes to the AS originating them. Addresses are looked up
+// by longest match.
+type Table struct {
+	prefixes map[netip.Prefix]uint32
+}
+
+// Parse reads a table with one "<prefix> <asn>" pair per line, as written
+// by pyasn or bgp.tools. The ASN may carry an "AS" prefix, blank lines
+// and lines starting with # are skipped.
+func Parse(r io.Reader) (*Table, error) {
+	t := &Table{prefixes: make(map[netip.Prefix]uint32)}
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("line %d: expected prefix and ASN", lineNo)
+		}
+		prefix, err := netip.ParsePrefix(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		asn, err := strconv.ParseUint(strings.TrimPrefix(strings.ToUpper(fields[1]), "AS"), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid ASN %q", lineNo, fields[1])
+		}
+		t.prefixes[prefix.Masked()] = uint32(asn)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Load reads the table file at path
+func Load(path string) (*Table, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+// Len returns the number of prefixes in the table
+func (t *Table) Len() int {
+	return len(t.prefixes)
+}
+
+// Lookup returns the most specific prefix covering addr and its origin AS
+func (t *Table) Lookup(addr string) (netip.Prefix, uint32, bool) {
+	ip, err := netip.ParseAddr(addr)
+	if err != nil {
+		return netip.Prefix{}, 0, false
+	}
+	ip = ip.Unmap()
+	for bits := ip.BitLen(); bits >= 0; bits-- {
+		prefix, _ := ip.Prefix(bits)
+		if asn, ok := t.prefixes[prefix]; ok {
+			return prefix, asn, true
+		}
+	}
+	return netip.Prefix{}, 0, false
+}
@@ -0,0 +1,51 @@
+package origintable
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	table, err := Parse(strings.NewReader(`# prefix asn
+192.0.2.0/24	64496
+192.0.2.128/25 AS64497
+
+198.51.100.1/24 64498
+2001:db8::/32 64499
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if table.Len() != 4 {
+		t.Errorf("Len() = %d", table.Len())
+	}
+
+	cases := []struct {
+		addr   string
+		prefix string
+		asn    uint32
+		ok     bool
+	}{
+		{"192.0.2.1", "192.0.2.0/24", 64496, true},
+		{"192.0.2.200", "192.0.2.128/25", 64497, true},
+		{"::ffff:192.0.2.1", "192.0.2.0/24", 64496, true},
+		{"198.51.100.77", "198.51.100.0/24", 64498, true},
+		{"2001:db8:1::1", "2001:db8::/32", 64499, true},
+		{"203.0.113.1", "", 0, false},
+		{"not an ip", "", 0, false},
+	}
+	for _, c := range cases {
+		prefix, asn, ok := table.Lookup(c.addr)
+		if ok != c.ok || asn != c.asn || (ok && prefix.String() != c.prefix) {
+			t.Errorf("Lookup(%q) = %v, %d, %v", c.addr, prefix, asn, ok)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, in := range []string{"192.0.2.0/24", "192.0.2.0/33 1", "192.0.2.0/24 ASx"} {
+		if _, err := Parse(strings.NewReader(in)); err == nil {
+			t.Errorf("Parse(%q) succeeded", in)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package protocolparser
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/summaryparser"
+)
+
+var ErrNoProtocol = errors.New("no protocol in output")
+
+// RouteCounts is the "Routes:" line of a channel
+type RouteCounts struct {
+	Imported  int `json:"imported"`
+	Filtered  int `json:"filtered"`
+	Exported  int `json:"exported"`
+	Preferred int `json:"preferred"`
+}
+
+// StatsRow is one row of the route change statistics, e.g. "Import updates".
+// Values are kept as printed, "---" marks cells BIRD does not count.
+type StatsRow struct {
+	Name   string   `json:"name"`
+	Values []string `json:"values"`
+}
+
+// StatsMatrix is the "Route change stats" table of a channel
+type StatsMatrix struct {
+	Columns []string   `json:"columns"`
+	Rows    []StatsRow `json:"rows"`
+}
+
+// Channel holds the per-channel part of the protocol details. BIRD 1.x has
+// no channels, its single implicit channel has an empty name.
+type Channel struct {
+	Name         string            `json:"name"`
+	State        string            `json:"state,omitempty"`
+	Table        string            `json:"table,omitempty"`
+	Preference   string            `json:"preference,omitempty"`
+	InputFilter  string            `json:"input_filter,omitempty"`
+	OutputFilter string            `json:"output_filter,omitempty"`
+	Routes       *RouteCounts      `json:"routes,omitempty"`
+	Stats        *StatsMatrix      `json:"stats,omitempty"`
+	Attributes   map[string]string `json:"attributes,omitempty"`
+}
+
+// Protocol is the parsed output of `show protocols all '<name>'`
+type Protocol struct {
+	Summary         summaryparser.SummaryRowData `json:"summary"`
+	Description     string                       `json:"description,omitempty"`
+	BGPState        string                       `json:"bgp_state,omitempty"`
+	NeighborAddress string                       `json:"neighbor_address,omitempty"`
+	NeighborAS      uint32                       `json:"neighbor_as,omitempty"`
+	LocalAS         uint32                       `json:"local_as,omitempty"`
+	NeighborID      string                       `json:"neighbor_id,omitempty"`
+	Session         string                       `json:"session,omitempty"`
+	SourceAddress   string                       `json:"source_address,omitempty"`
+	LastError       string                       `json:"last_error,omitempty"`
+	HoldTimer       string                       `json:"hold_timer,omitempty"`
+	KeepaliveTimer  string                       `json:"keepalive_timer,omitempty"`
+	Channels        []*Channel                   `json:"channels,omitempty"`
+	Attributes      map[string]string            `json:"attributes,omitempty"`
+}
+
+// IsBGP reports whether the protocol is a BGP session
+func (p *Protocol) IsBGP() bool {
+	return strings.EqualFold(p.Summary.Proto, "BGP")
+}
+
+// pre-compiled regexps for the protocol details
+var (
+	keyValueLine = regexp.MustCompile(`^(\s*)([A-Za-z][\w .-]*?):(?:\s+(.*?))?\s*$`)
+	channelLine  = regexp.MustCompile(`^\s+Channel\s+(\S+)\s*$`)
+	routeCount   = regexp.MustCompile(`(\d+)\s+(imported|filtered|exported|preferred)`)
+)
+
+// channelKeys are attributes that belong to a channel. BIRD 1.x prints
+// them at protocol level.
+var channelKeys = map[string]bool{
+	"State":              true,
+	"Table":              true,
+	"Preference":         true,
+	"Input filter":       true,
+	"Output filter":      true,
+	"Routes":             true,
+	"Route change stats": true,
+}
+
+var statsRows = map[string]bool{
+	"Import updates":   true,
+	"Import withdraws": true,
+	"Export updates":   true,
+	"Export withdraws": true,
+}
+
+func indentOf(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " \t"))
+}
+
+func parseRoutes(s string) *RouteCounts {
+	counts := &RouteCounts{}
+	for _, m := range routeCount.FindAllStringSubmatch(s, -1) {
+		n, _ := strconv.Atoi(m[1])
+		switch m[2] {
+		case "imported":
+			counts.Imported = n
+		case "filtered":
+			counts.Filtered = n
+		case "exported":
+			counts.Exported = n
+		case "preferred":
+			counts.Preferred = n
+		}
+	}
+	return counts
+}
+
+func parseASN(s string) uint32 {
+	v, _ := strconv.ParseUint(strings.TrimPrefix(strings.TrimSpace(s), "AS"), 10, 32)
+	return uint32(v)
+}
+
+func (p *Protocol) setAttribute(key, value string) {
+	switch key {
+	case "Description":
+		p.Description = value
+	case "BGP state":
+		p.BGPState = value
+	case "Neighbor address":
+		p.NeighborAddress = value
+	case "Neighbor AS":
+		p.NeighborAS = parseASN(value)
+	case "Local AS":
+		p.LocalAS = parseASN(value)
+	case "Neighbor ID":
+		p.NeighborID = value
+	case "Session":
+		p.Session = value
+	case "Source address":
+		p.SourceAddress = value
+	case "Last error":
+		p.LastError = value
+	case "Hold timer":
+		p.HoldTimer = value
+	case "Keepalive timer":
+		p.KeepaliveTimer = value
+	default:
+		p.Attributes[key] = value
+	}
+}
+
+func (c *Channel) setAttribute(key, value string) {
+	switch key {
+	case "State":
+		c.State = value
+	case "Table":
+		c.Table = value
+	case "Preference":
+		c.Preference = value
+	case "Input filter":
+		c.InputFilter = value
+	case "Output filter":
+		c.OutputFilter = value
+	case "Routes":
+		c.Routes = parseRoutes(value)
+	case "Route change stats":
+		c.Stats = &StatsMatrix{Columns: strings.Fields(value)}
+	default:
+		c.Attributes[key] = value
+	}
+}
+
+// Parse parses the text of `show protocols all '<name>'` for a single
+// protocol, as printed by BIRD 1.6, 2.x and 3.x.
+func Parse(data string) (*Protocol, error) {
+	var proto *Protocol
+	var channel *Channel
+	channelIndent := 0
+	implicit := false
+	skipIndent := -1
+
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		indent := indentOf(line)
+
+		if proto == nil {
+			if indent > 0 || strings.HasPrefix(strings.ToLower(line), "name ") {
+				continue
+			}
+			row := summaryparser.SummaryRowDataFromLine(line)
+			if row == nil {
+				continue
+			}
+			proto = &Protocol{
+				Summary:    *row,
+				Attributes: make(map[string]string),
+			}
+			continue
+		}
+
+		// The next protocol row ends the details
+		if indent == 0 {
+			break
+		}
+
+		if skipIndent >= 0 {
+			if indent > skipIndent {
+				continue
+			}
+			skipIndent = -1
+		}
+
+		if m := channelLine.FindStringSubmatch(line); m != nil {
+			channel = &Channel{
+				Name:       m[1],
+				Attributes: make(map[string]string),
+			}
+			channelIndent = indent
+			implicit = false
+			proto.Channels = append(proto.Channels, channel)
+			continue
+		}
+		if channel != nil && !implicit && indent <= channelIndent {
+			channel = nil
+		}
+
+		m := keyValueLine.FindStringSubmatch(line)
+		if m == nil {
+			// Blocks without a value, like "Local capabilities", are
+			// only shown in the raw output
+			skipIndent = indent
+			continue
+		}
+		key, value := m[2], m[3]
+
+		if channel == nil && channelKeys[key] {
+			// BIRD 1.x prints channel attributes at protocol level
+			channel = &Channel{
+				Table:      proto.Summary.Table,
+				Attributes: make(map[string]string),
+			}
+			implicit = true
+			proto.Channels = append(proto.Channels, channel)
+		}
+
+		if channel != nil && channel.Stats != nil && statsRows[key] {
+			channel.Stats.Rows = append(channel.Stats.Rows, StatsRow{
+				Name:   key,
+				Values: strings.Fields(value),
+			})
+			continue
+		}
+
+		if channel != nil && (!implicit || channelKeys[key]) {
+			channel.setAttribute(key, value)
+			continue
+		}
+		proto.setAttribute(key, value)
+	}
+
+	if proto == nil {
+		return nil, ErrNoProtocol
+	}
+	return proto, nil
+}
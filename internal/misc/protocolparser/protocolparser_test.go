@@ -0,0 +1,131 @@
+package protocolparser
+
+import "testing"
+
+const bird2Output = `Name       Proto      Table      State  Since         Info
+bgp_peer1  BGP        ---        up     2024-01-01    Established
+  Description:    Peer 1
+  BGP state:          Established
+    Neighbor address: 198.51.100.2
+    Neighbor AS:      64500
+    Local AS:         64496
+    Neighbor ID:      198.51.100.2
+    Local capabilities
+      Multiprotocol
+        AF announced: ipv4 ipv6
+      Route refresh
+    Neighbor capabilities
+      Multiprotocol
+        AF announced: ipv4
+    Session:          external AS4
+    Source address:   198.51.100.1
+    Hold timer:       180.000/240
+    Keepalive timer:  60.000/80
+  Channel ipv4
+    State:          UP
+    Table:          master4
+    Preference:     100
+    Input filter:   import_peer1
+    Output filter:  export_peer1
+    Routes:         10 imported, 2 filtered, 5 exported, 8 preferred
+    Route change stats:     received   rejected   filtered    ignored   accepted
+      Import updates:             12          0          2          0         10
+      Import withdraws:            0          0        ---          0          0
+      Export updates:             20          5          0        ---         15
+      Export withdraws:            0        ---        ---        ---          0
+    BGP Next hop:   198.51.100.1
+  Channel ipv6
+    State:          DOWN
+    Table:          master6
+    Preference:     100
+    Input filter:   ACCEPT
+    Output filter:  REJECT
+`
+
+const bird16Output = `name     proto    table    state  since       info
+bgp_peer2 BGP      master   start  2024-01-01  Active        Socket: Connection refused
+  Preference:     100
+  Input filter:   ACCEPT
+  Output filter:  REJECT
+  Routes:         0 imported, 0 exported, 0 preferred
+  Route change stats:     received   rejected   filtered    ignored   accepted
+    Import updates:              0          0          0          0          0
+    Import withdraws:            0          0        ---          0          0
+    Export updates:              0          0          0        ---          0
+    Export withdraws:            0        ---        ---        ---          0
+  BGP state:          Active
+    Neighbor address: 198.51.100.6
+    Neighbor AS:      64501
+    Connect delay:    3/5
+    Last error:       Socket: Connection refused
+`
+
+func TestParseBird2(t *testing.T) {
+	p, err := Parse(bird2Output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p.Summary.Name != "bgp_peer1" || !p.IsBGP() || p.Description != "Peer 1" {
+		t.Fatalf("unexpected protocol: %+v", p)
+	}
+	if p.NeighborAddress != "198.51.100.2" || p.NeighborAS != 64500 || p.LocalAS != 64496 {
+		t.Fatalf("unexpected neighbor: %+v", p)
+	}
+	if p.HoldTimer != "180.000/240" || p.KeepaliveTimer != "60.000/80" || p.Session != "external AS4" {
+		t.Fatalf("unexpected session details: %+v", p)
+	}
+	if _, ok := p.Attributes["AF announced"]; ok {
+		t.Fatal("capability details leaked into attributes")
+	}
+
+	if len(p.Channels) != 2 {
+		t.Fatalf("expected 2 channels, got %d", len(p.Channels))
+	}
+	ch := p.Channels[0]
+	if ch.Name != "ipv4" || ch.State != "UP" || ch.Table != "master4" || ch.InputFilter != "import_peer1" {
+		t.Fatalf("unexpected channel: %+v", ch)
+	}
+	if *ch.Routes != (RouteCounts{Imported: 10, Filtered: 2, Exported: 5, Preferred: 8}) {
+		t.Fatalf("unexpected route counts: %+v", ch.Routes)
+	}
+	if len(ch.Stats.Columns) != 5 || len(ch.Stats.Rows) != 4 {
+		t.Fatalf("unexpected stats: %+v", ch.Stats)
+	}
+	if ch.Stats.Rows[2].Name != "Export updates" || ch.Stats.Rows[2].Values[4] != "15" || ch.Stats.Rows[1].Values[2] != "---" {
+		t.Fatalf("unexpected stats rows: %+v", ch.Stats.Rows)
+	}
+	if ch.Attributes["BGP Next hop"] != "198.51.100.1" {
+		t.Fatalf("unexpected channel attributes: %+v", ch.Attributes)
+	}
+	if p.Channels[1].Name != "ipv6" || p.Channels[1].State != "DOWN" {
+		t.Fatalf("unexpected channel: %+v", p.Channels[1])
+	}
+}
+
+func TestParseBird16(t *testing.T) {
+	p, err := Parse(bird16Output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p.Summary.Name != "bgp_peer2" || p.BGPState != "Active" || p.NeighborAS != 64501 {
+		t.Fatalf("unexpected protocol: %+v", p)
+	}
+	if p.LastError != "Socket: Connection refused" || p.Attributes["Connect delay"] != "3/5" {
+		t.Fatalf("unexpected protocol details: %+v", p)
+	}
+	if len(p.Channels) != 1 {
+		t.Fatalf("expected 1 implicit channel, got %d", len(p.Channels))
+	}
+	ch := p.Channels[0]
+	if ch.Name != "" || ch.Table != "master" || ch.Routes == nil || len(ch.Stats.Rows) != 4 {
+		t.Fatalf("unexpected channel: %+v", ch)
+	}
+}
+
+func TestParseEmpty(t *testing.T) {
+	if _, err := Parse("No protocols match\n"); err != ErrNoProtocol {
+		t.Fatalf("expected ErrNoProtocol, got %v", err)
+	}
+}
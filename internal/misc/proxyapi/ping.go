@@ -0,0 +1,28 @@
+package proxyapi
+
+// PingProbe is a single echo request. RTT is in milliseconds.
+type PingProbe struct {
+	Seq     int     `json:"seq"`
+	Success bool    `json:"success"`
+	RTT     float64 `json:"rtt,omitempty"`
+}
+
+// PingResult is the answer to a ping request. Loss is in percent, times
+// are in milliseconds and only set if a reply was received. Source is the
+// source profile the probes were sent from, if one was chosen.
+type PingResult struct {
+	Target        string `json:"target"`
+	Address       string `json:"address"`
+	Source        string `json:"source,omitempty"`
+	SourceAddress string `json:"source_address,omitempty"`
+
+	Size     int         `json:"size"`
+	Probes   []PingProbe `json:"probes"`
+	Sent     int         `json:"sent"`
+	Received int         `json:"received"`
+	Loss     float64     `json:"loss"`
+	Min      float64     `json:"min"`
+	Avg      float64     `json:"avg"`
+	Max      float64     `json:"max"`
+	StdDev   float64     `json:"stddev"`
+}
@@ -0,0 +1,249 @@
+// Package proxyapi defines the typed requests the frontend sends to
+// proxies. Requests name a command type with typed arguments, and the
+// proxy builds the BIRD command from a fixed set of templates, so no
+// free-form daemon commands cross the wire.
+package proxyapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/capabilities"
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/validator"
+)
+
+// Version is the current version of the request envelope
+const Version = 1
+
+// Request types. The BIRD and traceroute types match the capability tools.
+const (
+	TypeInstances    = "instances"
+	TypeCapabilities = "capabilities"
+	TypeSummary      = capabilities.ToolSummary
+	TypeProtocol     = capabilities.ToolProtocol
+	TypeRoute        = capabilities.ToolRoute
+	TypeFilter       = capabilities.ToolFilter
+	TypeTraceroute   = capabilities.ToolTraceroute
+	TypePing         = capabilities.ToolPing
+)
+
+// Traceroute probe protocols
+const (
+	ProbeICMP = "icmp"
+	ProbeTCP  = "tcp"
+	ProbeUDP  = "udp"
+)
+
+// Address families a traceroute or ping may be restricted to
+const (
+	FamilyAny  = ""
+	FamilyIPv4 = "ipv4"
+	FamilyIPv6 = "ipv6"
+)
+
+// Output formats of traceroute requests. FormatJSONLines streams the JSON
+// document as TracerouteEvent lines. FormatHTML is kept for frontends
+// older than the JSON document.
+const (
+	FormatText      = "text"
+	FormatJSON      = "json"
+	FormatJSONLines = "jsonl"
+	FormatHTML      = "html"
+)
+
+var (
+	ErrUnsupportedVersion = errors.New("unsupported request version")
+	ErrUnknownType        = errors.New("unknown request type")
+	ErrInvalidArgs        = errors.New("invalid request arguments")
+)
+
+// Args are the typed arguments of a request. Each type uses some of them.
+type Args struct {
+	// Protocol is a BIRD protocol name
+	Protocol string `json:"protocol,omitempty"`
+	// Prefix is an IP address or CIDR prefix to look up
+	Prefix string `json:"prefix,omitempty"`
+	// All asks for all route attributes
+	All bool `json:"all,omitempty"`
+	// Target is the IP address or domain name to trace or ping
+	Target string `json:"target,omitempty"`
+	// Format of the traceroute output
+	Format string `json:"format,omitempty"`
+	// Count of ping probes or traceroute probes per hop, zero for the
+	// proxy's default
+	Count int `json:"count,omitempty"`
+	// Interval between ping probes in milliseconds, zero for the default
+	Interval int `json:"interval,omitempty"`
+	// Size of the ping payload in bytes, zero for the default
+	Size int `json:"size,omitempty"`
+	// Family resolves the target to an IPv4 or IPv6 address only
+	Family string `json:"family,omitempty"`
+	// Probe is the traceroute probe protocol, "icmp" if empty
+	Probe string `json:"probe,omitempty"`
+	// Port is the destination port of TCP and UDP probes, zero for the
+	// proxy's default
+	Port int `json:"port,omitempty"`
+	// MaxHops of a traceroute, zero for the proxy's default
+	MaxHops int `json:"max_hops,omitempty"`
+	// Source names the source profile of the proxy to send traceroute
+	// and ping probes from, empty to let the proxy pick the address
+	Source string `json:"source,omitempty"`
+}
+
+// validateTarget checks the target, address family and source profile
+// name of a traceroute or ping
+func (a Args) validateTarget() error {
+	isV4, isV6 := validator.IsIP(a.Target)
+	if !(isV4 || isV6 || validator.IsDomain(a.Target)) {
+		return fmt.Errorf("%w: target %q", ErrInvalidArgs, a.Target)
+	}
+	if a.Family != FamilyAny && a.Family != FamilyIPv4 && a.Family != FamilyIPv6 {
+		return fmt.Errorf("%w: family %q", ErrInvalidArgs, a.Family)
+	}
+	if (a.Family == FamilyIPv4 && isV6) || (a.Family == FamilyIPv6 && isV4) {
+		return fmt.Errorf("%w: target %q is not %s", ErrInvalidArgs, a.Target, a.Family)
+	}
+	if a.Source != "" && !validator.IsValidSourceName(a.Source) {
+		return fmt.Errorf("%w: source %q", ErrInvalidArgs, a.Source)
+	}
+	return nil
+}
+
+// Request is the envelope of a proxy request
+type Request struct {
+	Version  int    `json:"version"`
+	Type     string `json:"type"`
+	Instance string `json:"instance,omitempty"`
+	Args     Args   `json:"args"`
+}
+
+// New returns a request of the current version
+func New(typ, instance string, args Args) *Request {
+	return &Request{
+		Version:  Version,
+		Type:     typ,
+		Instance: instance,
+		Args:     args,
+	}
+}
+
+// birdTemplates build the BIRD command of each BIRD request type from
+// validated arguments
+var birdTemplates = map[string]func(Args) string{
+	TypeSummary: func(Args) string {
+		return "show protocols"
+	},
+	TypeProtocol: func(a Args) string {
+		return "show protocols all '" + a.Protocol + "'"
+	},
+	TypeRoute: func(a Args) string {
+		if a.All {
+			return "show route for " + a.Prefix + " all"
+		}
+		return "show route for " + a.Prefix
+	},
+	TypeFilter: func(a Args) string {
+		return "show route filtered all protocol '" + a.Protocol + "'"
+	},
+}
+
+// IsBird reports whether the request runs a BIRD command
+func (r *Request) IsBird() bool {
+	_, ok := birdTemplates[r.Type]
+	return ok
+}
+
+// Endpoint returns the key scope endpoint the request needs, empty for
+// requests describing the proxy
+func (r *Request) Endpoint() string {
+	switch {
+	case r.IsBird():
+		return "bird"
+	case r.Type == TypeTraceroute:
+		return "traceroute"
+	case r.Type == TypePing:
+		return "ping"
+	}
+	return ""
+}
+
+// Validate checks the version, type and arguments of the request
+func (r *Request) Validate() error {
+	if r.Version != Version {
+		return ErrUnsupportedVersion
+	}
+
+	a := r.Args
+	switch r.Type {
+	case TypeInstances, TypeCapabilities, TypeSummary:
+		return nil
+	case TypeProtocol, TypeFilter:
+		if !validator.IsValidProtocol(a.Protocol) {
+			return fmt.Errorf("%w: protocol %q", ErrInvalidArgs, a.Protocol)
+		}
+	case TypeRoute:
+		isV4, isV6 := validator.IsIP(a.Prefix)
+		isV4CIDR, isV6CIDR := validator.IsCIDR(a.Prefix)
+		if !(isV4 || isV6 || isV4CIDR || isV6CIDR) {
+			return fmt.Errorf("%w: prefix %q", ErrInvalidArgs, a.Prefix)
+		}
+	case TypeTraceroute:
+		if err := a.validateTarget(); err != nil {
+			return err
+		}
+		switch a.Format {
+		case "", FormatText, FormatJSON, FormatJSONLines, FormatHTML:
+		default:
+			return fmt.Errorf("%w: format %q", ErrInvalidArgs, a.Format)
+		}
+		if a.Probe != "" && a.Probe != ProbeICMP && a.Probe != ProbeTCP && a.Probe != ProbeUDP {
+			return fmt.Errorf("%w: probe %q", ErrInvalidArgs, a.Probe)
+		}
+		if a.Port < 0 || a.Port > 65535 || (a.Port != 0 && (a.Probe == "" || a.Probe == ProbeICMP)) {
+			return fmt.Errorf("%w: port %d", ErrInvalidArgs, a.Port)
+		}
+		if a.Count < 0 || a.MaxHops < 0 {
+			return fmt.Errorf("%w: negative traceroute setting", ErrInvalidArgs)
+		}
+	case TypePing:
+		if err := a.validateTarget(); err != nil {
+			return err
+		}
+		if a.Count < 0 || a.Interval < 0 || a.Size < 0 {
+			return fmt.Errorf("%w: negative ping setting", ErrInvalidArgs)
+		}
+	default:
+		return fmt.Errorf("%w: %q", ErrUnknownType, r.Type)
+	}
+	return nil
+}
+
+// BirdCommand builds the BIRD command of a valid BIRD request
+func (r *Request) BirdCommand() (string, error) {
+	tmpl, ok := birdTemplates[r.Type]
+	if !ok {
+		return "", fmt.Errorf("%w: %q is not a BIRD request", ErrUnknownType, r.Type)
+	}
+	if err := r.Validate(); err != nil {
+		return "", err
+	}
+	return tmpl(r.Args), nil
+}
+
+// Decode parses and validates a request envelope. Unknown fields are
+// rejected, so a newer frontend does not silently lose arguments.
+func Decode(data []byte) (*Request, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+
+	var r Request
+	if err := dec.Decode(&r); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArgs, err)
+	}
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
@@ -0,0 +1,72 @@
+package proxyapi
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestBirdCommand(t *testing.T) {
+	tests := []struct {
+		req *Request
+		cmd string
+		err error
+	}{
+		{New(TypeSummary, "", Args{}), "show protocols", nil},
+		{New(TypeProtocol, "bird6", Args{Protocol: "bgp_peer1"}), "show protocols all 'bgp_peer1'", nil},
+		{New(TypeRoute, "", Args{Prefix: "192.0.2.0/24", All: true}), "show route for 192.0.2.0/24 all", nil},
+		{New(TypeRoute, "", Args{Prefix: "2001:db8::1"}), "show route for 2001:db8::1", nil},
+		{New(TypeFilter, "", Args{Protocol: "bgp_peer1"}), "show route filtered all protocol 'bgp_peer1'", nil},
+		{New(TypeProtocol, "", Args{Protocol: "x'; configure"}), "", ErrInvalidArgs},
+		{New(TypeRoute, "", Args{Prefix: "192.0.2.1 all; down"}), "", ErrInvalidArgs},
+		{New(TypeTraceroute, "", Args{Target: "192.0.2.1"}), "", ErrUnknownType},
+		{&Request{Version: 2, Type: TypeSummary}, "", ErrUnsupportedVersion},
+	}
+
+	for _, tt := range tests {
+		cmd, err := tt.req.BirdCommand()
+		if !errors.Is(err, tt.err) || cmd != tt.cmd {
+			t.Errorf("%s %+v: got %q, %v, want %q, %v", tt.req.Type, tt.req.Args, cmd, err, tt.cmd, tt.err)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	data, _ := json.Marshal(New(TypeTraceroute, "", Args{Target: "example.com", Format: FormatHTML}))
+	r, err := Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Type != TypeTraceroute || r.Args.Target != "example.com" || r.Endpoint() != "traceroute" {
+		t.Errorf("decoded %+v", r)
+	}
+
+	data, _ = json.Marshal(New(TypeTraceroute, "", Args{Target: "2001:db8::1", Probe: ProbeTCP, Port: 443, Family: FamilyIPv6, Format: FormatJSONLines}))
+	if _, err := Decode(data); err != nil {
+		t.Errorf("Decode(%s): %v", data, err)
+	}
+
+	data, _ = json.Marshal(New(TypePing, "", Args{Target: "example.com", Count: 3, Family: FamilyIPv6, Source: "anycast"}))
+	if r, err := Decode(data); err != nil || r.Endpoint() != "ping" {
+		t.Errorf("Decode(%s) = %+v, %v", data, r, err)
+	}
+
+	bad := []string{
+		`{"version":1,"type":"summary","args":{},"command":"configure"}`,
+		`{"version":1,"type":"shell","args":{}}`,
+		`{"version":1,"type":"traceroute","args":{"target":"example.com","format":"pdf"}}`,
+		`{"version":1,"type":"ping","args":{"target":"192.0.2.1","family":"ipv6"}}`,
+		`{"version":1,"type":"traceroute","args":{"target":"example.com","probe":"sctp"}}`,
+		`{"version":1,"type":"traceroute","args":{"target":"example.com","port":443}}`,
+		`{"version":1,"type":"traceroute","args":{"target":"example.com","probe":"tcp","port":65536}}`,
+		`{"version":1,"type":"ping","args":{"target":"example.com","count":-1}}`,
+		`{"version":1,"type":"ping","args":{"target":"example.com","source":"lo; reboot"}}`,
+		`{"version":1,"type":"traceroute","args":{"target":"example.com","source":"-anycast"}}`,
+		`not json`,
+	}
+	for _, b := range bad {
+		if _, err := Decode([]byte(b)); err == nil {
+			t.Errorf("Decode(%s) succeeded", b)
+		}
+	}
+}
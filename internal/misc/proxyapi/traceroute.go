@@ -0,0 +1,65 @@
+package proxyapi
+
+import "time"
+
+// TracerouteVersion is the version of the traceroute document format
+const TracerouteVersion = 1
+
+// TracerouteProbe are the probing settings a traceroute ran with. Timeout
+// is in milliseconds, Port is only set for TCP and UDP probes. Source is
+// the source profile the probes were sent from, if one was chosen, and
+// SourceAddress its address.
+type TracerouteProbe struct {
+	Protocol string `json:"protocol"`
+	Port     int    `json:"port,omitempty"`
+	MaxHops  int    `json:"max_hops"`
+	Count    int    `json:"count"`
+	Timeout  int    `json:"timeout"`
+	Size     int    `json:"size"`
+
+	Source        string `json:"source,omitempty"`
+	SourceAddress string `json:"source_address,omitempty"`
+}
+
+// TracerouteHop is a hop of a traceroute. Loss is in percent, times are
+// in milliseconds. Hops without a reply have no address and 100% loss.
+// Prefix is the route covering the address and ASN the AS originating it,
+// if the proxy knows them.
+type TracerouteHop struct {
+	Hop     int     `json:"hop"`
+	Address string  `json:"address,omitempty"`
+	PTR     string  `json:"ptr,omitempty"`
+	Prefix  string  `json:"prefix,omitempty"`
+	ASN     uint32  `json:"asn,omitempty"`
+	Success bool    `json:"success"`
+	Loss    float64 `json:"loss"`
+	Sent    int     `json:"sent"`
+	Last    float64 `json:"last"`
+	Avg     float64 `json:"avg"`
+	Best    float64 `json:"best"`
+	Worst   float64 `json:"worst"`
+	// Final marks the last hop if it did not reply
+	Final bool `json:"final,omitempty"`
+}
+
+// TracerouteResult is the document a traceroute request in the JSON
+// format is answered with
+type TracerouteResult struct {
+	Version int `json:"version"`
+	// Target as requested, Address is what it resolved to
+	Target  string          `json:"target"`
+	Address string          `json:"address"`
+	Start   time.Time       `json:"start"`
+	End     time.Time       `json:"end"`
+	Probe   TracerouteProbe `json:"probe"`
+	Hops    []TracerouteHop `json:"hops"`
+}
+
+// TracerouteEvent is a line of a traceroute in the FormatJSONLines format.
+// The first line carries the Result without hops and end time, then each
+// hop follows as soon as it is measured and the last line carries End.
+type TracerouteEvent struct {
+	Result *TracerouteResult `json:"result,omitempty"`
+	Hop    *TracerouteHop    `json:"hop,omitempty"`
+	End    *time.Time        `json:"end,omitempty"`
+}
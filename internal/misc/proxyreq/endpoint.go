@@ -0,0 +1,145 @@
+package proxyreq
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/LaunchPad-Network/NetPeek/internal/logger"
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/crypto/signature"
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/net"
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/tlsconfig"
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/tunnel"
+
+	"github.com/lfcypo/viperx"
+	"github.com/spf13/viper"
+)
+
+var log = logger.New("ProxyReq")
+
+// endpoint is where the proxy of a PoP is reached
+type endpoint struct {
+	ID     string `mapstructure:"id"`
+	Scheme string `mapstructure:"scheme"`
+	Host   string `mapstructure:"host"`
+	Port   int    `mapstructure:"port"`
+	// SNI is the server name sent and verified over TLS, the host by default
+	SNI string `mapstructure:"sni"`
+	// PoPs with a tunnel secret connect to the frontend instead
+	TunnelSecret     string `mapstructure:"tunnel_secret"`
+	TunnelSecretFile string `mapstructure:"tunnel_secret_file"`
+}
+
+func (e endpoint) tunnelSecret() (string, error) {
+	if e.TunnelSecretFile != "" {
+		return signature.ReadKeyFile(e.TunnelSecretFile)
+	}
+	return e.TunnelSecret, nil
+}
+
+func (e endpoint) baseURL() string {
+	return e.Scheme + "://" + e.Host + ":" + strconv.Itoa(e.Port)
+}
+
+type endpointsConfig struct {
+	Servers struct {
+		Proxies []endpoint `mapstructure:"proxies"`
+	} `mapstructure:"servers"`
+}
+
+var endpoints map[string]endpoint
+var endpointsOnce sync.Once
+
+func loadEndpoints() {
+	endpoints = make(map[string]endpoint)
+
+	var cfg endpointsConfig
+	if err := viper.Unmarshal(&cfg); err != nil {
+		log.Error("Failed to load servers.proxies: ", err)
+		return
+	}
+	for _, e := range cfg.Servers.Proxies {
+		endpoints[strings.ToLower(e.ID)] = e
+	}
+}
+
+// endpointOf returns the endpoint of a PoP. Fields not set in
+// servers.proxies default to servers.proxy_scheme, the PoP ID with
+// servers.proxy_suffix and servers.proxy_port.
+func endpointOf(node string) endpoint {
+	endpointsOnce.Do(loadEndpoints)
+
+	e := endpoints[strings.ToLower(node)]
+	if e.Scheme == "" {
+		e.Scheme = viperx.GetString("servers.proxy_scheme", "http")
+	}
+	if e.Host == "" {
+		e.Host = node + viper.GetString("servers.proxy_suffix")
+	}
+	if e.Port == 0 {
+		e.Port = viperx.GetInt("servers.proxy_port", 10179)
+	}
+	if e.SNI == "" {
+		e.SNI = e.Host
+	}
+	return e
+}
+
+// clients holds one HTTP client per PoP, so connections are reused and
+// each verifies its own server name
+var clients sync.Map
+
+// ErrNotConnected is returned for PoPs using a tunnel that is not open
+var ErrNotConnected = errors.New("PoP is not connected")
+
+// TunnelSecret returns the hex encoded tunnel secret of a PoP, or false
+// if it does not connect through a tunnel
+func TunnelSecret(node string) (string, bool) {
+	secret, err := endpointOf(node).tunnelSecret()
+	if err != nil {
+		log.Errorf("Failed to load tunnel secret of %s: %v", node, err)
+		return "", false
+	}
+	return secret, secret != ""
+}
+
+// UsesTunnel reports whether a PoP connects through a tunnel
+func UsesTunnel(node string) bool {
+	e := endpointOf(node)
+	return e.TunnelSecret != "" || e.TunnelSecretFile != ""
+}
+
+func clientFor(node string) (*http.Client, error) {
+	if client, ok := tunnel.Client(node); ok {
+		return client, nil
+	}
+	if UsesTunnel(node) {
+		return nil, ErrNotConnected
+	}
+	e := endpointOf(node)
+
+	key := e.Scheme + " " + e.SNI
+	if client, ok := clients.Load(key); ok {
+		return client.(*http.Client), nil
+	}
+
+	timeout := viperx.GetInt("servers.timeout", 5)
+	if e.Scheme != "https" {
+		client, _ := clients.LoadOrStore(key, net.NewClient(timeout, nil))
+		return client.(*http.Client), nil
+	}
+
+	cfg, err := tlsconfig.Client(tlsconfig.ClientOptions{
+		CAFile:     viper.GetString("servers.tls.ca_file"),
+		CertFile:   viper.GetString("servers.tls.cert_file"),
+		KeyFile:    viper.GetString("servers.tls.key_file"),
+		ServerName: e.SNI,
+	})
+	if err != nil {
+		return nil, err
+	}
+	client, _ := clients.LoadOrStore(key, net.NewClient(timeout, cfg))
+	return client.(*http.Client), nil
+}
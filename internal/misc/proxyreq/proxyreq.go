@@ -1,45 +1,173 @@
 package proxyreq
 
 import (
-	"net/url"
-	"strconv"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
 
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/bird"
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/capabilities"
 	"github.com/LaunchPad-Network/NetPeek/internal/misc/net"
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/proxyapi"
 	"github.com/LaunchPad-Network/NetPeek/internal/misc/proxyreqsign"
-
-	"github.com/lfcypo/viperx"
-	"github.com/spf13/viper"
 )
 
-func buildProxyUrl(node, kind, q string) (string, error) {
-	reqS, err := proxyreqsign.Sign(q)
+// ErrBusy is returned when a proxy has no room for another measurement
+var ErrBusy = errors.New("PoP is busy")
+
+// statusError turns a non-200 proxy response into an error.
+// BIRD failures come back as a *bird.ReplyError.
+func statusError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	msg := strings.TrimSpace(string(body))
+	if class := resp.Header.Get(bird.ErrorClassHeader); class != "" {
+		return bird.ErrorFromClass(class, msg)
+	}
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		return fmt.Errorf("%w: %s", ErrBusy, msg)
+	}
+	return fmt.Errorf("proxy returned %s: %s", resp.Status, msg)
+}
+
+// post signs a typed request and sends it to the proxy of node. The
+// caller must close the response body.
+func post(ctx context.Context, node string, req *proxyapi.Request) (*http.Response, error) {
+	body, err := json.Marshal(req)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	reqS, err := proxyreqsign.SignBody("query", node, body)
+	if err != nil {
+		return nil, err
+	}
+	client, err := clientFor(node)
+	if err != nil {
+		return nil, err
 	}
-	proxyUrl := "http://" + node + viper.GetString("servers.proxy_suffix") + ":" + viperx.GetString("servers.proxy_port", "10179") + "/" + kind + "?q=" + url.QueryEscape(reqS.Query) + "&ts=" + strconv.FormatInt(reqS.Ts, 10) + "&sig=" + reqS.Signature
-	return proxyUrl, nil
+
+	url := endpointOf(node).baseURL() + "/query?" + reqS.Params().Encode()
+	resp, err := net.PostURLWithClient(ctx, client, url, "application/json", body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, statusError(resp)
+	}
+	return resp, nil
 }
 
-func BirdRequest(node, q string) (string, error) {
-	url, err := buildProxyUrl(node, "bird", q)
+// Query runs a request on a PoP and returns the whole output
+func Query(node string, req *proxyapi.Request) (string, error) {
+	resp, err := post(context.Background(), node, req)
 	if err != nil {
 		return "", err
 	}
-	return net.FetchURLWithTimeoutAsPlaintext(url, viperx.GetInt("servers.timeout", 5))
-}
+	defer resp.Body.Close()
 
-func TracerouteRequest(node, q string) (string, error) {
-	url, err := buildProxyUrl(node, "traceroute", q)
+	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return "", err
 	}
-	return net.FetchURLWithTimeoutAsPlaintext(url, viperx.GetInt("servers.timeout", 5))
+	return string(body), nil
 }
 
-func TracerouteHTMLRequest(node, q string) (string, error) {
-	url, err := buildProxyUrl(node, "tracerouteh", q)
+// QueryStream runs a request on a PoP and returns the output to be read
+// while the proxy is still sending it. The caller must close it.
+func QueryStream(ctx context.Context, node string, req *proxyapi.Request) (io.ReadCloser, error) {
+	resp, err := post(ctx, node, req)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// queryJSON runs a request and decodes its JSON answer into v
+func queryJSON(node string, req *proxyapi.Request, v any) error {
+	resp, err := Query(node, req)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(resp), v)
+}
+
+// InstancesRequest lists the BIRD instances a PoP serves
+func InstancesRequest(node string) ([]bird.Instance, error) {
+	var instances []bird.Instance
+	if err := queryJSON(node, proxyapi.New(proxyapi.TypeInstances, "", proxyapi.Args{}), &instances); err != nil {
+		return nil, err
+	}
+	return instances, nil
+}
+
+// CapabilitiesRequest asks a PoP which tools it offers and whether its
+// BIRD instances are up
+func CapabilitiesRequest(node string) (*capabilities.Capabilities, error) {
+	var caps capabilities.Capabilities
+	if err := queryJSON(node, proxyapi.New(proxyapi.TypeCapabilities, "", proxyapi.Args{}), &caps); err != nil {
+		return nil, err
+	}
+	return &caps, nil
+}
+
+// PingRequest pings a target from a PoP
+func PingRequest(node string, args proxyapi.Args) (*proxyapi.PingResult, error) {
+	var res proxyapi.PingResult
+	if err := queryJSON(node, proxyapi.New(proxyapi.TypePing, "", args), &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// TracerouteRequest runs a traceroute on a PoP and returns its document
+func TracerouteRequest(node string, req *proxyapi.Request) (*proxyapi.TracerouteResult, error) {
+	jsonReq := *req
+	jsonReq.Args.Format = proxyapi.FormatJSON
+
+	var res proxyapi.TracerouteResult
+	if err := queryJSON(node, &jsonReq, &res); err != nil {
+		return nil, err
+	}
+	if res.Version != proxyapi.TracerouteVersion {
+		return nil, fmt.Errorf("unsupported traceroute document version %d", res.Version)
+	}
+	return &res, nil
+}
+
+// TracerouteEvents runs a traceroute on a PoP and calls onEvent for every
+// line of its document while the proxy is still measuring
+func TracerouteEvents(ctx context.Context, node string, req *proxyapi.Request, onEvent func(proxyapi.TracerouteEvent) error) error {
+	linesReq := *req
+	linesReq.Args.Format = proxyapi.FormatJSONLines
+
+	body, err := QueryStream(ctx, node, &linesReq)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	dec := json.NewDecoder(body)
+	for first := true; ; first = false {
+		var ev proxyapi.TracerouteEvent
+		if err := dec.Decode(&ev); err != nil {
+			// The proxy ends a complete traceroute with an End line
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return err
+		}
+		if first && (ev.Result == nil || ev.Result.Version != proxyapi.TracerouteVersion) {
+			return fmt.Errorf("unsupported traceroute document")
+		}
+		if err := onEvent(ev); err != nil {
+			return err
+		}
+		if ev.End != nil {
+			return nil
+		}
 	}
-	return net.FetchURLWithTimeoutAsPlaintext(url, viperx.GetInt("servers.timeout", 5))
 }
@@ -0,0 +1,263 @@
+package proxyreqsign
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/crypto/ecdsa"
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/crypto/hmac"
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/crypto/signature"
+
+	"github.com/lfcypo/viperx"
+	"github.com/spf13/viper"
+)
+
+// trustedKey is a public key the proxy accepts signatures from
+type trustedKey struct {
+	ID        string
+	Key       signature.Verifier
+	NotBefore time.Time
+	NotAfter  time.Time
+	Scope     *Scope
+}
+
+// validAt reports whether the key may be used at t. Zero bounds are open.
+func (k *trustedKey) validAt(t time.Time) bool {
+	if !k.NotBefore.IsZero() && t.Before(k.NotBefore) {
+		return false
+	}
+	if !k.NotAfter.IsZero() && t.After(k.NotAfter) {
+		return false
+	}
+	return true
+}
+
+type trustedKeyEntry struct {
+	ID            string   `mapstructure:"id"`
+	Algorithm     string   `mapstructure:"algorithm"`
+	PublicKey     string   `mapstructure:"publickey"`
+	PublicKeyFile string   `mapstructure:"publickey_file"`
+	Secret        string   `mapstructure:"secret"`
+	SecretFile    string   `mapstructure:"secret_file"`
+	NotBefore     string   `mapstructure:"not_before"`
+	NotAfter      string   `mapstructure:"not_after"`
+	Endpoints     []string `mapstructure:"endpoints"`
+	Commands      []string `mapstructure:"commands"`
+	Unrestricted  bool     `mapstructure:"unrestricted"`
+}
+
+type keysConfig struct {
+	Authentication struct {
+		TrustedKeys []trustedKeyEntry `mapstructure:"trusted_keys"`
+	} `mapstructure:"authentication"`
+}
+
+var signer signature.Signer
+var signingKeyID string
+var trustedKeys = make(map[string]*trustedKey)
+
+// acceptLegacy accepts the signatures of frontends from before payload
+// v2 on the endpoints they called, see verifyLegacy
+var acceptLegacy bool
+
+// KeyID derives the default ID of a key from the fingerprint of its hex
+// encoded public key
+func KeyID(pubHex string) string {
+	sum := sha256.Sum256([]byte(pubHex))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Algorithm is the configured default signature algorithm
+func Algorithm() string {
+	return viperx.GetString("authentication.algorithm", signature.Default)
+}
+
+// keyHex returns a hex key given inline or as the path of a key file
+func keyHex(inline, file string) (string, error) {
+	if file != "" {
+		return signature.ReadKeyFile(file)
+	}
+	return inline, nil
+}
+
+func parseValidity(id, field, value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s of trusted key %q: %w", field, id, err)
+	}
+	return t, nil
+}
+
+func addTrustedKey(key *trustedKey) error {
+	if _, ok := trustedKeys[key.ID]; ok {
+		return fmt.Errorf("duplicate trusted key ID %q", key.ID)
+	}
+	trustedKeys[key.ID] = key
+	return nil
+}
+
+// LoadSigningKey loads the key the frontend signs requests with from
+// authentication.privatekey or authentication.privatekey_file
+func LoadSigningKey() error {
+	alg := Algorithm()
+	privHex, err := keyHex(viper.GetString("authentication.privatekey"), viper.GetString("authentication.privatekey_file"))
+	if err != nil {
+		return err
+	}
+	if privHex == "" {
+		return fmt.Errorf("no private key configured, create one with the keygen command")
+	}
+
+	s, err := signature.NewSigner(alg, privHex)
+	if err != nil {
+		return fmt.Errorf("failed to load %s private key: %w", alg, err)
+	}
+	signer = s
+	signingKeyID = viper.GetString("authentication.key_id")
+	if signingKeyID == "" {
+		pubHex, _ := signature.PublicKeyHex(alg, privHex)
+		signingKeyID = KeyID(pubHex)
+	}
+	return nil
+}
+
+// SigningKeyID returns the ID requests are signed with
+func SigningKeyID() string {
+	return signingKeyID
+}
+
+// LoadTrustedKeys loads the keys the proxy accepts signatures from
+func LoadTrustedKeys() error {
+	alg := Algorithm()
+	acceptLegacy = viperx.GetBool("authentication.accept_legacy_signatures", false)
+	trustedKeys = make(map[string]*trustedKey)
+
+	// The single key of older configurations is trusted without bounds
+	pubHex, err := keyHex(viper.GetString("authentication.publickey"), viper.GetString("authentication.publickey_file"))
+	if err != nil {
+		return err
+	}
+	if pubHex != "" {
+		pubk, err := signature.NewVerifier(alg, pubHex)
+		if err != nil {
+			return fmt.Errorf("failed to load %s public key: %w", alg, err)
+		}
+		if err := addTrustedKey(&trustedKey{ID: KeyID(pubHex), Key: pubk, Scope: &Scope{}}); err != nil {
+			return err
+		}
+	}
+
+	var cfg keysConfig
+	if err := viper.Unmarshal(&cfg); err != nil {
+		return fmt.Errorf("failed to load trusted keys: %w", err)
+	}
+	for _, ent := range cfg.Authentication.TrustedKeys {
+		if err := loadTrustedKey(ent, alg); err != nil {
+			return err
+		}
+	}
+
+	if len(trustedKeys) == 0 {
+		return fmt.Errorf("no trusted public keys configured")
+	}
+	if acceptLegacy && trustsAlgorithm(ecdsa.Algorithm) {
+		log.Warn("Signatures of frontends from before payload v2 are accepted. They only cover the " +
+			"first 32 bytes of the request, set authentication.accept_legacy_signatures = false " +
+			"once all frontends are updated.")
+	}
+	return nil
+}
+
+func loadTrustedKey(ent trustedKeyEntry, alg string) error {
+	if ent.Algorithm != "" {
+		alg = ent.Algorithm
+	}
+	inline, file := ent.PublicKey, ent.PublicKeyFile
+	if alg == hmac.Algorithm {
+		inline, file = ent.Secret, ent.SecretFile
+	}
+	pubHex, err := keyHex(inline, file)
+	if err != nil {
+		return fmt.Errorf("failed to load trusted key %q: %w", ent.ID, err)
+	}
+	pubk, err := signature.NewVerifier(alg, pubHex)
+	if err != nil {
+		return fmt.Errorf("failed to load trusted key %q: %w", ent.ID, err)
+	}
+
+	id := ent.ID
+	if id == "" {
+		id = KeyID(pubHex)
+	}
+	scope, err := newScope(ent.Endpoints, ent.Commands, ent.Unrestricted)
+	if err != nil {
+		return fmt.Errorf("invalid scope of trusted key %q: %w", id, err)
+	}
+	notBefore, err := parseValidity(id, "not_before", ent.NotBefore)
+	if err != nil {
+		return err
+	}
+	notAfter, err := parseValidity(id, "not_after", ent.NotAfter)
+	if err != nil {
+		return err
+	}
+	return addTrustedKey(&trustedKey{
+		ID:        id,
+		Key:       pubk,
+		NotBefore: notBefore,
+		NotAfter:  notAfter,
+		Scope:     scope,
+	})
+}
+
+func trustsAlgorithm(alg string) bool {
+	for _, key := range trustedKeys {
+		if key.Key.Algorithm() == alg {
+			return true
+		}
+	}
+	return false
+}
+
+// TrustedKeyInfo describes a trusted key for diagnostics
+type TrustedKeyInfo struct {
+	ID        string
+	Algorithm string
+	NotBefore time.Time
+	NotAfter  time.Time
+	Scope     *Scope
+}
+
+// TrustedKeys lists the loaded trusted keys sorted by ID
+func TrustedKeys() []TrustedKeyInfo {
+	var keys []TrustedKeyInfo
+	for _, key := range trustedKeys {
+		keys = append(keys, TrustedKeyInfo{
+			ID:        key.ID,
+			Algorithm: key.Key.Algorithm(),
+			NotBefore: key.NotBefore,
+			NotAfter:  key.NotAfter,
+			Scope:     key.Scope,
+		})
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	return keys
+}
+
+// lookupKey finds the trusted key a request was signed with
+func lookupKey(id string, now time.Time) (*trustedKey, error) {
+	key, ok := trustedKeys[id]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	if !key.validAt(now) {
+		return nil, ErrKeyNotValid
+	}
+	return key, nil
+}
@@ -1,75 +1,269 @@
 package proxyreqsign
 
 import (
-	fmtEcdsa "crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/LaunchPad-Network/NetPeek/constant"
 	"github.com/LaunchPad-Network/NetPeek/internal/logger"
 	"github.com/LaunchPad-Network/NetPeek/internal/misc/crypto/ecdsa"
 
+	"github.com/lfcypo/viperx"
 	"github.com/spf13/viper"
 )
 
 var log = logger.New("ProxyReq Sign")
-var privKey *fmtEcdsa.PrivateKey
-var pubKey *fmtEcdsa.PublicKey
 
-func init() {
-	loadKeys()
+// ErrNoSigningKey is returned by Sign without authentication.privatekey
+var ErrNoSigningKey = errors.New("no signing key configured")
+
+// Version is the current version of the signed payload format
+const Version = "2"
+
+// SignedProxyRequest is a frontend request to a proxy. The signature
+// covers the endpoint kind (e.g. "bird"), the HTTP method and the node the
+// request is meant for, so it is only valid for that very request. The
+// body of POST requests is covered by its SHA-256 hash.
+type SignedProxyRequest struct {
+	Version   string
+	Method    string
+	Kind      string
+	Node      string
+	KeyID     string
+	Query     string
+	Instance  string
+	Nonce     string
+	Ts        int64
+	Signature string
+	// Unrestricted asks the proxy to run the BIRD command outside
+	// restricted mode, which the key's scope must allow
+	Unrestricted bool
+	// Body of POST requests, nil otherwise
+	Body []byte
+
+	// scope of the key the request was signed with, set by Verify
+	scope *Scope
 }
 
-func loadKeys() {
-	prik, err := ecdsa.ImportPrivateKeyHex(viper.GetString("authentication.privatekey"))
+// signedParams are the request parameters covered by the signature
+func (spr *SignedProxyRequest) signedParams() url.Values {
+	params := url.Values{}
+	params.Set("q", spr.Query)
+	if spr.Body != nil {
+		sum := sha256.Sum256(spr.Body)
+		params.Set("body", hex.EncodeToString(sum[:]))
+	}
+	if spr.Instance != "" {
+		params.Set("instance", spr.Instance)
+	}
+	if spr.Unrestricted {
+		params.Set("unrestricted", "1")
+	}
+	params.Set("kid", spr.KeyID)
+	params.Set("n", spr.Nonce)
+	params.Set("ts", strconv.FormatInt(spr.Ts, 10))
+	return params
+}
+
+// payload builds the canonical signed string: a version line, then the
+// method, kind, node and the sorted, escaped parameters on one line each.
+func (spr *SignedProxyRequest) payload() string {
+	return strings.Join([]string{
+		"netpeek-proxy-v" + spr.Version,
+		spr.Method,
+		spr.Kind,
+		spr.Node,
+		spr.signedParams().Encode(),
+	}, "\n")
+}
+
+// Params returns the URL parameters carrying the signed request
+func (spr *SignedProxyRequest) Params() url.Values {
+	params := spr.signedParams()
+	params.Set("v", spr.Version)
+	params.Set("node", spr.Node)
+	params.Set("sig", spr.Signature)
+	return params
+}
+
+// clockSkew is the tolerated clock difference between frontend and proxy
+func clockSkew() int64 {
+	return int64(viperx.GetInt("authentication.clock_skew", int(constant.ProxyReqSignMaxClockSkew.Seconds())))
+}
+
+// Verify checks that the request is meant for node, then the timestamp,
+// the signing key and signature and that the nonce was not used before. Rejections are
+// counted by reason, see Rejections.
+func (spr *SignedProxyRequest) Verify(node string) error {
+	err := spr.verify(node, time.Now())
 	if err != nil {
-		log.Error("Failed to load ECDSA private key: ", err)
+		countRejection(err)
+	}
+	return err
+}
+
+func (spr *SignedProxyRequest) verify(node string, at time.Time) error {
+	now := at.Unix()
+	if spr.Version == "" && acceptLegacy {
+		return spr.verifyLegacy(at)
+	}
+	if spr.Version != Version {
+		return ErrUnsupportedVersion
+	}
+	if spr.Nonce == "" || spr.Signature == "" || spr.Kind == "" || spr.Method == "" {
+		return ErrMalformed
+	}
+	if spr.Node != node {
+		return ErrWrongNode
 	}
 
-	pubk, err := ecdsa.ImportPublicKeyHex(viper.GetString("authentication.publickey"))
+	validity := int64(constant.ProxyReqSignValidityDuration.Seconds())
+	skew := clockSkew()
+	if spr.Ts < now-validity-skew {
+		return ErrExpired
+	}
+	if spr.Ts > now+skew {
+		return ErrFuture
+	}
+
+	key, err := lookupKey(spr.KeyID, at)
 	if err != nil {
-		log.Error("Failed to load ECDSA public key: ", err)
-
-		newPriv, err := ecdsa.GenerateKey()
-		if err != nil {
-			log.Error("Failed to generate new ECDSA key pair: ", err)
-		} else {
-			newPub := &newPriv.PublicKey
-			privHex := ecdsa.ExportPrivateKeyHex(newPriv)
-			pubHex := ecdsa.ExportPublicKeyHex(newPub)
-			log.Info("Generated new ECDSA key pair for signing proxy requests.")
-			log.Infof("Private Key: %s", privHex)
-			log.Infof("Public Key: %s", pubHex)
+		return err
+	}
+	if !key.Key.Verify([]byte(spr.payload()), spr.Signature) {
+		return ErrBadSignature
+	}
+
+	// Only signed nonces are remembered, so the cache cannot be filled
+	// with forged requests
+	if err := getReplayCache().add(spr.Nonce, spr.Ts+validity+skew, now); err != nil {
+		return err
+	}
+	spr.scope = key.Scope
+	return nil
+}
+
+// legacyKinds are the endpoints frontends called before payload v2
+var legacyKinds = map[string]bool{
+	"bird":        true,
+	"traceroute":  true,
+	"tracerouteh": true,
+}
+
+// verifyLegacy checks a request of a frontend from before payload v2: an
+// ECDSA signature in the JSON encoding over "q=<query>,ts=<ts>", without
+// key ID, nonce or node. Parameters those frontends did not send are
+// refused, as they would not be covered by the signature. The signature
+// itself stands in for the nonce.
+func (spr *SignedProxyRequest) verifyLegacy(at time.Time) error {
+	if spr.Method != http.MethodGet || !legacyKinds[spr.Kind] {
+		return ErrUnsupportedVersion
+	}
+	if spr.Signature == "" || spr.Body != nil || spr.KeyID != "" || spr.Nonce != "" ||
+		spr.Node != "" || spr.Instance != "" || spr.Unrestricted {
+		return ErrMalformed
+	}
+
+	now := at.Unix()
+	validity := int64(constant.ProxyReqSignValidityDuration.Seconds())
+	skew := clockSkew()
+	if spr.Ts < now-validity-skew {
+		return ErrExpired
+	}
+	if spr.Ts > now+skew {
+		return ErrFuture
+	}
+
+	// Those frontends did not escape the base64 signature in the URL, so
+	// its plus signs arrive as spaces
+	sig := strings.ReplaceAll(spr.Signature, " ", "+")
+	payload := []byte(fmt.Sprintf("q=%s,ts=%d", spr.Query, spr.Ts))
+	for _, key := range trustedKeys {
+		v, ok := key.Key.(*ecdsa.Verifier)
+		if !ok || !key.validAt(at) || !ecdsa.VerifyLegacy(v.Key, payload, sig) {
+			continue
+		}
+		if err := getReplayCache().add(sig, spr.Ts+validity+skew, now); err != nil {
+			return err
 		}
+		spr.KeyID = key.ID
+		spr.scope = key.Scope
+		return nil
+	}
+	return ErrBadSignature
+}
 
-		log.Fatal("Cannot continue without valid ECDSA public key")
+// Scope returns the scope of the key a verified request was signed with
+func (spr *SignedProxyRequest) Scope() *Scope {
+	if spr.scope == nil {
+		return &Scope{}
 	}
+	return spr.scope
+}
 
-	privKey = prik
-	pubKey = pubk
+// newNonce returns a random value making every signed request unique
+func newNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
 }
 
-type SignedProxyRequest struct {
-	Query     string
-	Ts        int64
-	Signature string
+// Sign signs a request to the kind endpoint of node. BIRD commands are
+// requested unrestricted if authentication.unrestricted is set.
+func Sign(method, kind, node, q, instance string) (*SignedProxyRequest, error) {
+	return sign(&SignedProxyRequest{
+		Method:   method,
+		Kind:     kind,
+		Node:     node,
+		Query:    q,
+		Instance: instance,
+
+		Unrestricted: kind == "bird" && viper.GetBool("authentication.unrestricted"),
+	})
 }
 
-func (spr *SignedProxyRequest) Verify() bool {
-	if spr.Ts < time.Now().Unix()-int64(constant.ProxyReqSignValidityDuration) {
-		return false
+// SignBody signs a POST request with body to the kind endpoint of node.
+// BIRD commands are requested unrestricted if authentication.unrestricted
+// is set.
+func SignBody(kind, node string, body []byte) (*SignedProxyRequest, error) {
+	if body == nil {
+		body = []byte{}
 	}
-	toVerify := fmt.Sprintf("q=%s,ts=%d", spr.Query, spr.Ts)
-	return ecdsa.VerifyText(pubKey, toVerify, spr.Signature)
+	return sign(&SignedProxyRequest{
+		Method: http.MethodPost,
+		Kind:   kind,
+		Node:   node,
+		Body:   body,
+
+		Unrestricted: viper.GetBool("authentication.unrestricted"),
+	})
 }
 
-func Sign(q string) (*SignedProxyRequest, error) {
-	ts := time.Now().Unix()
-	toSign := fmt.Sprintf("q=%s,ts=%d", q, ts)
-	sig := ecdsa.SignText(privKey, toSign)
-	return &SignedProxyRequest{
-		Query:     q,
-		Ts:        ts,
-		Signature: sig,
-	}, nil
+func sign(spr *SignedProxyRequest) (*SignedProxyRequest, error) {
+	if signer == nil {
+		return nil, ErrNoSigningKey
+	}
+	nonce, err := newNonce()
+	if err != nil {
+		return nil, err
+	}
+	spr.Version = Version
+	spr.KeyID = signingKeyID
+	spr.Nonce = nonce
+	spr.Ts = time.Now().Unix()
+	spr.Signature, err = signer.Sign([]byte(spr.payload()))
+	if err != nil {
+		return nil, err
+	}
+	return spr, nil
 }
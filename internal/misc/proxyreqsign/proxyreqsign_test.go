@@ -0,0 +1,93 @@
+package proxyreqsign
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/crypto/ecdsa"
+)
+
+func TestVerifyLegacy(t *testing.T) {
+	key, err := ecdsa.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	trustedKeys = map[string]*trustedKey{
+		"old": {ID: "old", Key: &ecdsa.Verifier{Key: &key.PublicKey}, Scope: &Scope{}},
+	}
+	defer func() {
+		trustedKeys = make(map[string]*trustedKey)
+		acceptLegacy = false
+	}()
+
+	now := time.Now()
+	// legacy returns a request as frontends sent it before payload v2
+	legacy := func(kind, q string) *SignedProxyRequest {
+		sig, err := ecdsa.SignLegacy(key, []byte(fmt.Sprintf("q=%s,ts=%d", q, now.Unix())))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &SignedProxyRequest{
+			Method: http.MethodGet,
+			Kind:   kind,
+			Query:  q,
+			Ts:     now.Unix(),
+			// The unescaped signature turns plus signs into spaces
+			Signature: strings.ReplaceAll(sig, "+", " "),
+		}
+	}
+
+	cases := []struct {
+		name   string
+		accept bool
+		spr    func() *SignedProxyRequest
+		want   error
+	}{
+		{"bird", true, func() *SignedProxyRequest { return legacy("bird", "show protocols") }, nil},
+		{"traceroute", true, func() *SignedProxyRequest { return legacy("traceroute", "192.0.2.1") }, nil},
+		{"not accepted", false, func() *SignedProxyRequest { return legacy("bird", "show protocols") }, ErrUnsupportedVersion},
+		{"query endpoint", true, func() *SignedProxyRequest {
+			spr := legacy("query", "")
+			spr.Method = http.MethodPost
+			return spr
+		}, ErrUnsupportedVersion},
+		{"unsigned instance", true, func() *SignedProxyRequest {
+			spr := legacy("bird", "show protocols")
+			spr.Instance = "bird6"
+			return spr
+		}, ErrMalformed},
+		{"other query", true, func() *SignedProxyRequest {
+			spr := legacy("bird", "show protocols")
+			spr.Query = "show status"
+			return spr
+		}, ErrBadSignature},
+		{"expired", true, func() *SignedProxyRequest {
+			spr := legacy("bird", "show protocols")
+			spr.Ts -= 3600
+			return spr
+		}, ErrExpired},
+	}
+	for _, c := range cases {
+		acceptLegacy = c.accept
+		spr := c.spr()
+		if err := spr.verify("node1", now); !errors.Is(err, c.want) {
+			t.Errorf("%s: verify() = %v, want %v", c.name, err, c.want)
+		} else if err == nil && spr.KeyID != "old" {
+			t.Errorf("%s: KeyID = %q", c.name, spr.KeyID)
+		}
+	}
+
+	acceptLegacy = true
+	spr := legacy("bird", "show protocols")
+	replayed := *spr
+	if err := spr.verify("node1", now); err != nil {
+		t.Fatal(err)
+	}
+	if err := replayed.verify("node1", now); !errors.Is(err, ErrReplayed) {
+		t.Errorf("replayed: verify() = %v, want %v", err, ErrReplayed)
+	}
+}
@@ -0,0 +1,64 @@
+package proxyreqsign
+
+import (
+	"errors"
+	"sync"
+)
+
+var (
+	ErrMalformed          = errors.New("malformed request")
+	ErrUnsupportedVersion = errors.New("unsupported signature version")
+	ErrWrongNode          = errors.New("request meant for another node")
+	ErrExpired            = errors.New("timestamp expired")
+	ErrFuture             = errors.New("timestamp in the future")
+	ErrReplayed           = errors.New("nonce already used")
+	ErrUnknownKey         = errors.New("unknown key ID")
+	ErrKeyNotValid        = errors.New("key not valid at this time")
+	ErrBadSignature       = errors.New("bad signature")
+	ErrReplayCacheFull    = errors.New("replay cache full")
+)
+
+// rejectionReasons are the names rejections are counted under
+var rejectionReasons = map[error]string{
+	ErrMalformed:          "malformed",
+	ErrUnsupportedVersion: "unsupported_version",
+	ErrWrongNode:          "wrong_node",
+	ErrExpired:            "expired",
+	ErrFuture:             "future",
+	ErrReplayed:           "replayed",
+	ErrUnknownKey:         "unknown_key",
+	ErrKeyNotValid:        "key_not_valid",
+	ErrBadSignature:       "bad_signature",
+	ErrReplayCacheFull:    "replay_cache_full",
+}
+
+var rejectionsMu sync.Mutex
+var rejections = make(map[string]uint64)
+
+// RejectionReason returns the name a verification error is counted under
+func RejectionReason(err error) string {
+	for kind, reason := range rejectionReasons {
+		if errors.Is(err, kind) {
+			return reason
+		}
+	}
+	return "unknown"
+}
+
+func countRejection(err error) {
+	rejectionsMu.Lock()
+	defer rejectionsMu.Unlock()
+	rejections[RejectionReason(err)]++
+}
+
+// Rejections returns how many requests were rejected for each reason
+func Rejections() map[string]uint64 {
+	rejectionsMu.Lock()
+	defer rejectionsMu.Unlock()
+
+	counts := make(map[string]uint64, len(rejections))
+	for reason, n := range rejections {
+		counts[reason] = n
+	}
+	return counts
+}
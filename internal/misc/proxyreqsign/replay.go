@@ -0,0 +1,65 @@
+package proxyreqsign
+
+import (
+	"sync"
+
+	"github.com/LaunchPad-Network/NetPeek/constant"
+	"github.com/lfcypo/viperx"
+)
+
+// replayCache remembers the nonces of accepted requests until their
+// timestamps fall out of the validity window. It holds at most size
+// nonces and refuses new ones when full rather than forgetting any
+// that could still be replayed.
+type replayCache struct {
+	mu    sync.Mutex
+	size  int
+	seen  map[string]int64
+	order []string
+}
+
+func newReplayCache(size int) *replayCache {
+	return &replayCache{
+		size: size,
+		seen: make(map[string]int64),
+	}
+}
+
+// purge drops nonces that expired before now, oldest first
+func (rc *replayCache) purge(now int64) {
+	for len(rc.order) > 0 {
+		nonce := rc.order[0]
+		if rc.seen[nonce] > now {
+			return
+		}
+		delete(rc.seen, nonce)
+		rc.order = rc.order[1:]
+	}
+}
+
+// add records a nonce that stays valid until expires
+func (rc *replayCache) add(nonce string, expires, now int64) error {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	rc.purge(now)
+	if _, ok := rc.seen[nonce]; ok {
+		return ErrReplayed
+	}
+	if len(rc.seen) >= rc.size {
+		return ErrReplayCacheFull
+	}
+	rc.seen[nonce] = expires
+	rc.order = append(rc.order, nonce)
+	return nil
+}
+
+var replays *replayCache
+var replaysOnce sync.Once
+
+func getReplayCache() *replayCache {
+	replaysOnce.Do(func() {
+		replays = newReplayCache(viperx.GetInt("authentication.replay_cache_size", constant.ProxyReqReplayCacheSize))
+	})
+	return replays
+}
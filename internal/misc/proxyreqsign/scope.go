@@ -0,0 +1,92 @@
+package proxyreqsign
+
+import (
+	"fmt"
+
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/cmdgrammar"
+)
+
+// Scope limits what requests signed with a key may do. The zero Scope
+// allows every endpoint and the proxy's default commands in restricted
+// mode.
+type Scope struct {
+	// Endpoints the key may call, e.g. "bird" or "traceroute". Empty
+	// allows all of them.
+	Endpoints []string
+	// Commands narrows bird.allowed_commands for this key if set: a
+	// command has to match both
+	Commands *cmdgrammar.Grammar
+	// Unrestricted allows running BIRD commands outside restricted mode
+	Unrestricted bool
+}
+
+func newScope(endpoints, commands []string, unrestricted bool) (*Scope, error) {
+	scope := &Scope{
+		Endpoints:    endpoints,
+		Unrestricted: unrestricted,
+	}
+	if len(commands) > 0 {
+		g, err := cmdgrammar.Compile(commands)
+		if err != nil {
+			return nil, err
+		}
+		scope.Commands = g
+	}
+	return scope, nil
+}
+
+// PermissionError names the permission a request was missing
+type PermissionError struct {
+	Permission string
+}
+
+func (e *PermissionError) Error() string {
+	return "missing permission: " + e.Permission
+}
+
+// AllowsEndpoint reports whether the key may call an endpoint
+func (s *Scope) AllowsEndpoint(kind string) bool {
+	if len(s.Endpoints) == 0 {
+		return true
+	}
+	for _, e := range s.Endpoints {
+		if e == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckEndpoint returns a *PermissionError if the key may not call kind
+func (s *Scope) CheckEndpoint(kind string) error {
+	if !s.AllowsEndpoint(kind) {
+		return &PermissionError{Permission: "endpoint " + kind}
+	}
+	return nil
+}
+
+// AllowsCommand reports whether the key may send q. It has to match the
+// proxy's commands, and the key's own commands if it has any.
+func (s *Scope) AllowsCommand(global *cmdgrammar.Grammar, q string) bool {
+	if _, ok := global.Match(q); !ok {
+		return false
+	}
+	if s.Commands != nil {
+		if _, ok := s.Commands.Match(q); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckCommand returns a *PermissionError if the key may not send q in
+// the requested mode
+func (s *Scope) CheckCommand(global *cmdgrammar.Grammar, q string, unrestricted bool) error {
+	if unrestricted && !s.Unrestricted {
+		return &PermissionError{Permission: "unrestricted"}
+	}
+	if !s.AllowsCommand(global, q) {
+		return &PermissionError{Permission: fmt.Sprintf("command %q", q)}
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package proxyreqsign
+
+import (
+	"testing"
+
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/cmdgrammar"
+)
+
+func TestCheckCommand(t *testing.T) {
+	global, err := cmdgrammar.Compile([]string{
+		"show protocols",
+		"show route for <ip|cidr> [all]",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scoped, err := newScope(nil, []string{"show route for <ip|cidr>", "show status"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unscoped, _ := newScope(nil, nil, true)
+
+	cases := []struct {
+		name         string
+		scope        *Scope
+		q            string
+		unrestricted bool
+		ok           bool
+	}{
+		{"default commands", unscoped, "show protocols", false, true},
+		{"outside the global commands", unscoped, "show status", false, false},
+		{"unrestricted", unscoped, "show protocols", true, true},
+		{"in both", scoped, "show route for 192.0.2.0/24", false, true},
+		{"only in the global commands", scoped, "show protocols", false, false},
+		{"only in the key's commands", scoped, "show status", false, false},
+		{"unrestricted not granted", scoped, "show route for 192.0.2.0/24", true, false},
+	}
+	for _, c := range cases {
+		err := c.scope.CheckCommand(global, c.q, c.unrestricted)
+		if (err == nil) != c.ok {
+			t.Errorf("%s: CheckCommand(%q) = %v", c.name, c.q, err)
+		}
+	}
+}
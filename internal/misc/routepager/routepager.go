@@ -0,0 +1,107 @@
+package routepager
+
+import (
+	"bufio"
+	"io"
+	"strings"
+
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/bird"
+)
+
+// Options selects one page of a listing. A Size of 0 disables paging.
+type Options struct {
+	Page int
+	Size int
+}
+
+// Result describes what was left out of a page
+type Result struct {
+	HasNext bool
+	// Truncated tells why the proxy cut off the output, e.g.
+	// "more than 10000 routes"
+	Truncated string
+}
+
+func isTableHeader(line string) bool {
+	return strings.HasPrefix(line, "Table ") && strings.HasSuffix(strings.TrimRight(line, "\r\n"), ":")
+}
+
+// Copy reads a route listing from r and passes the lines of the selected
+// page to emit. A page holds Size prefixes: a route starts at a line that
+// is not indented and keeps every indented line after it, including
+// alternative routes for the same prefix. The table header is repeated at
+// the top of every page.
+//
+// emit is called with the complete routes read whenever r has nothing
+// more buffered, so a stream is relayed as it arrives. Copy stops reading as soon as it knows there is
+// a next page.
+func Copy(r io.Reader, opts Options, emit func(string) error) (Result, error) {
+	var res Result
+	if opts.Page < 1 {
+		opts.Page = 1
+	}
+	first := (opts.Page - 1) * opts.Size
+
+	// Lines of the current route are kept in record until the next route
+	// starts, so emit only gets complete routes to format
+	br := bufio.NewReader(r)
+	var chunk, record strings.Builder
+	endRecord := func() {
+		chunk.WriteString(record.String())
+		record.Reset()
+	}
+	flush := func() error {
+		if chunk.Len() == 0 {
+			return nil
+		}
+		s := chunk.String()
+		chunk.Reset()
+		return emit(s)
+	}
+
+	current := -1
+	table := ""
+	for {
+		line, err := br.ReadString('\n')
+		if line != "" {
+			switch {
+			case strings.HasPrefix(line, bird.TruncatedMarker):
+				res.Truncated = strings.Trim(strings.TrimPrefix(line, bird.TruncatedMarker), ":- \r\n")
+			case isTableHeader(line):
+				endRecord()
+				table = line
+			default:
+				if line[0] != ' ' && line[0] != '\t' && strings.TrimSpace(line) != "" {
+					current++
+					endRecord()
+				}
+				if opts.Size > 0 && current >= first+opts.Size {
+					res.HasNext = true
+					return res, flush()
+				}
+				if opts.Size == 0 || current >= first || (current < 0 && first == 0) {
+					if table != "" {
+						record.WriteString(table)
+						table = ""
+					}
+					record.WriteString(line)
+				}
+			}
+		}
+
+		if err == io.EOF {
+			endRecord()
+			return res, flush()
+		}
+		if err != nil {
+			endRecord()
+			flush()
+			return res, err
+		}
+		if br.Buffered() == 0 {
+			if err := flush(); err != nil {
+				return res, err
+			}
+		}
+	}
+}
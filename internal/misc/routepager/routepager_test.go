@@ -0,0 +1,82 @@
+package routepager
+
+import (
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+const listing = `Table master4:
+192.0.2.0/24         unicast [bgp_peer1 2024-01-01] * (100) [AS64500i]
+	via 198.51.100.1 on eth0
+	Type: BGP univ
+                     unicast [bgp_peer2 2024-01-01] (100) [AS64502i]
+	via 198.51.100.3 on eth1
+198.51.100.0/24      unicast [bgp_peer1 2024-01-01] * (100) [AS64500i]
+	via 198.51.100.1 on eth0
+203.0.113.0/24       unicast [bgp_peer1 2024-01-01] * (100) [AS64500i]
+	via 198.51.100.1 on eth0
+--- output truncated: more than 3 routes ---
+`
+
+func copyPage(t *testing.T, opts Options) (string, Result) {
+	var out strings.Builder
+	res, err := Copy(strings.NewReader(listing), opts, func(s string) error {
+		out.WriteString(s)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out.String(), res
+}
+
+func TestCopyPages(t *testing.T) {
+	out, res := copyPage(t, Options{Page: 1, Size: 2})
+	if !res.HasNext || res.Truncated != "" {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if strings.Count(out, "\n") != 8 || strings.Contains(out, "203.0.113.0/24") {
+		t.Fatalf("unexpected first page:\n%s", out)
+	}
+
+	out, res = copyPage(t, Options{Page: 2, Size: 2})
+	if res.HasNext || res.Truncated != "more than 3 routes" {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if !strings.HasPrefix(out, "Table master4:\n203.0.113.0/24") || strings.Count(out, "\n") != 3 {
+		t.Fatalf("unexpected second page:\n%s", out)
+	}
+}
+
+func TestCopyAll(t *testing.T) {
+	out, res := copyPage(t, Options{})
+	if res.HasNext || res.Truncated == "" {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if strings.Count(out, "\n") != 10 {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+}
+
+func TestCopyWholeRoutes(t *testing.T) {
+	// Reading one byte at a time, every line ends a read
+	var chunks []string
+	_, err := Copy(iotest.OneByteReader(strings.NewReader(listing)), Options{}, func(s string) error {
+		chunks = append(chunks, s)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"Table master4:\n192.0.2.0/24", "198.51.100.0/24", "203.0.113.0/24"}
+	if len(chunks) != len(want) {
+		t.Fatalf("got %d chunks, want %d: %q", len(chunks), len(want), chunks)
+	}
+	for i, prefix := range want {
+		if !strings.HasPrefix(chunks[i], prefix) {
+			t.Errorf("chunk %d = %q, want a route starting with %q", i, chunks[i], prefix)
+		}
+	}
+}
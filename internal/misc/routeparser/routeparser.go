@@ -0,0 +1,249 @@
+package routeparser
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// NextHop is one gateway of a route
+type NextHop struct {
+	Via       string `json:"via,omitempty"`
+	Interface string `json:"interface,omitempty"`
+	Weight    int    `json:"weight,omitempty"`
+}
+
+type Community struct {
+	ASN   uint32 `json:"asn"`
+	Value uint32 `json:"value"`
+}
+
+type LargeCommunity struct {
+	Global uint32 `json:"global"`
+	Local1 uint32 `json:"local1"`
+	Local2 uint32 `json:"local2"`
+}
+
+// BGPAttributes holds the BGP path attributes of a route.
+// All contains every BGP attribute by its canonical name, e.g. "as_path",
+// whether or not it has a typed field.
+type BGPAttributes struct {
+	Origin           string            `json:"origin,omitempty"`
+	ASPath           []uint32          `json:"as_path,omitempty"`
+	NextHop          []string          `json:"next_hop,omitempty"`
+	MED              *uint32           `json:"med,omitempty"`
+	LocalPref        *uint32           `json:"local_pref,omitempty"`
+	Communities      []Community       `json:"communities,omitempty"`
+	LargeCommunities []LargeCommunity  `json:"large_communities,omitempty"`
+	ExtCommunities   []string          `json:"ext_communities,omitempty"`
+	All              map[string]string `json:"all"`
+}
+
+// Route is one route of a `show route ... all` listing
+type Route struct {
+	Prefix     string            `json:"prefix"`
+	Table      string            `json:"table,omitempty"`
+	Type       string            `json:"type,omitempty"`
+	Protocol   string            `json:"protocol"`
+	Age        string            `json:"age"`
+	From       string            `json:"from,omitempty"`
+	Primary    bool              `json:"primary"`
+	Preference int               `json:"preference"`
+	OriginAS   uint32            `json:"origin_as,omitempty"`
+	NextHops   []NextHop         `json:"next_hops,omitempty"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+	BGP        *BGPAttributes    `json:"bgp,omitempty"`
+}
+
+// pre-compiled regexps for the route listing
+var (
+	tableLine = regexp.MustCompile(`^Table (\S+):\s*$`)
+	routeLine = regexp.MustCompile(`^(\S*)\s+(.*?)\s*\[(\S+)\s+([^\]]*?)(?:\s+from\s+(\S+))?\]\s*(\*)?\s*(?:[A-Z][A-Z0-9]*\s+)?\((\d+)(?:/[^)]*)?\)(?:\s*\[(?:AS(\d+))?[ie?]\])?`)
+	viaPart   = regexp.MustCompile(`^via\s+(\S+)(?:\s+on\s+(\S+))?(?:.*?\s+weight\s+(\d+))?`)
+	devPart   = regexp.MustCompile(`^dev\s+(\S+)`)
+	attrLine  = regexp.MustCompile(`^\s+([A-Za-z][\w. ]*?):(?:\s+(.*))?$`)
+	tupleRe   = regexp.MustCompile(`\((\d+),\s*(\d+)(?:,\s*(\d+))?\)`)
+	parenRe   = regexp.MustCompile(`\([^)]*\)`)
+)
+
+// canonicalBGPName maps BIRD 1.x/2.x "BGP.as_path" and BIRD 3.x style
+// "bgp_path" attribute names to one canonical name. It returns false for
+// non-BGP attributes.
+func canonicalBGPName(key string) (string, bool) {
+	lower := strings.ToLower(key)
+	var name string
+	switch {
+	case strings.HasPrefix(lower, "bgp."):
+		name = lower[len("bgp."):]
+	case strings.HasPrefix(lower, "bgp_"):
+		name = lower[len("bgp_"):]
+	default:
+		return "", false
+	}
+	if name == "path" {
+		name = "as_path"
+	}
+	return name, true
+}
+
+func parseUint32(s string) (uint32, bool) {
+	v, err := strconv.ParseUint(s, 10, 32)
+	return uint32(v), err == nil
+}
+
+// parseHeader parses the part of a route line between the prefix and the
+// protocol bracket: the route type for BIRD 2.x and later, or the gateway
+// for BIRD 1.x.
+func (r *Route) parseHeader(kind string) {
+	if m := viaPart.FindStringSubmatch(kind); m != nil {
+		hop := NextHop{Via: m[1], Interface: m[2]}
+		hop.Weight, _ = strconv.Atoi(m[3])
+		r.NextHops = append(r.NextHops, hop)
+		r.Type = "unicast"
+		return
+	}
+	if m := devPart.FindStringSubmatch(kind); m != nil {
+		r.NextHops = append(r.NextHops, NextHop{Interface: m[1]})
+		r.Type = "unicast"
+		return
+	}
+	r.Type = kind
+}
+
+// parseNextHopLine handles the indented gateway lines of BIRD 2.x and
+// multipath routes of BIRD 1.x
+func (r *Route) parseNextHopLine(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	if m := viaPart.FindStringSubmatch(trimmed); m != nil {
+		hop := NextHop{Via: m[1], Interface: m[2]}
+		hop.Weight, _ = strconv.Atoi(m[3])
+		r.NextHops = append(r.NextHops, hop)
+		return true
+	}
+	if m := devPart.FindStringSubmatch(trimmed); m != nil {
+		r.NextHops = append(r.NextHops, NextHop{Interface: m[1]})
+		return true
+	}
+	return false
+}
+
+// finish derives the typed BGP attributes from the raw attribute map
+func (r *Route) finish() {
+	for key, value := range r.Attributes {
+		name, ok := canonicalBGPName(key)
+		if !ok {
+			continue
+		}
+		if r.BGP == nil {
+			r.BGP = &BGPAttributes{All: make(map[string]string)}
+		}
+		r.BGP.All[name] = value
+	}
+	if r.BGP == nil {
+		return
+	}
+
+	bgp := r.BGP
+	bgp.Origin = bgp.All["origin"]
+	for _, asn := range strings.Fields(strings.NewReplacer("{", " ", "}", " ").Replace(bgp.All["as_path"])) {
+		if v, ok := parseUint32(asn); ok {
+			bgp.ASPath = append(bgp.ASPath, v)
+		}
+	}
+	bgp.NextHop = strings.Fields(bgp.All["next_hop"])
+	if v, ok := parseUint32(bgp.All["med"]); ok {
+		bgp.MED = &v
+	}
+	if v, ok := parseUint32(bgp.All["local_pref"]); ok {
+		bgp.LocalPref = &v
+	}
+	for _, m := range tupleRe.FindAllStringSubmatch(bgp.All["community"], -1) {
+		asn, _ := parseUint32(m[1])
+		value, _ := parseUint32(m[2])
+		bgp.Communities = append(bgp.Communities, Community{ASN: asn, Value: value})
+	}
+	for _, m := range tupleRe.FindAllStringSubmatch(bgp.All["large_community"], -1) {
+		global, _ := parseUint32(m[1])
+		local1, _ := parseUint32(m[2])
+		local2, _ := parseUint32(m[3])
+		bgp.LargeCommunities = append(bgp.LargeCommunities, LargeCommunity{Global: global, Local1: local1, Local2: local2})
+	}
+	bgp.ExtCommunities = parenRe.FindAllString(bgp.All["ext_community"], -1)
+}
+
+// Parse parses the text of `show route ... all` as printed by BIRD 1.6,
+// 2.x and 3.x. Lines it does not understand are skipped.
+func Parse(data string) []Route {
+	var routes []Route
+	var cur *Route
+	var lastAttr string
+	table := ""
+	prefix := ""
+
+	flush := func() {
+		if cur != nil {
+			cur.finish()
+			routes = append(routes, *cur)
+			cur = nil
+		}
+		lastAttr = ""
+	}
+
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		if m := tableLine.FindStringSubmatch(line); m != nil {
+			flush()
+			table = m[1]
+			continue
+		}
+
+		if m := routeLine.FindStringSubmatch(line); m != nil {
+			flush()
+			if m[1] != "" {
+				prefix = m[1]
+			}
+			cur = &Route{
+				Prefix:     prefix,
+				Table:      table,
+				Protocol:   m[3],
+				Age:        m[4],
+				From:       m[5],
+				Primary:    m[6] == "*",
+				Attributes: make(map[string]string),
+			}
+			cur.parseHeader(m[2])
+			cur.Preference, _ = strconv.Atoi(m[7])
+			if asn, ok := parseUint32(m[8]); ok {
+				cur.OriginAS = asn
+			}
+			continue
+		}
+
+		if cur == nil {
+			continue
+		}
+
+		// Wrapped attribute values are indented further than attributes
+		if lastAttr != "" && (strings.HasPrefix(line, "\t\t") || strings.HasPrefix(strings.TrimSpace(line), "(")) {
+			cur.Attributes[lastAttr] += " " + strings.TrimSpace(line)
+			continue
+		}
+
+		if cur.parseNextHopLine(line) {
+			lastAttr = ""
+			continue
+		}
+
+		if m := attrLine.FindStringSubmatch(line); m != nil {
+			lastAttr = m[1]
+			cur.Attributes[lastAttr] = strings.TrimSpace(m[2])
+		}
+	}
+	flush()
+
+	return routes
+}
@@ -0,0 +1,170 @@
+package routeparser
+
+import (
+	"testing"
+)
+
+const bird16Output = `192.0.2.0/24       via 198.51.100.1 on eth0 [bgp_peer1 2024-01-01 from 198.51.100.2] * (100/10) [AS64500i]
+	Type: BGP unicast univ
+	BGP.origin: IGP
+	BGP.as_path: 64500 64501
+	BGP.next_hop: 198.51.100.1
+	BGP.med: 10
+	BGP.local_pref: 100
+	BGP.community: (64500,1) (64500,2)
+	BGP.large_community: (64500, 1, 2)
+                   via 198.51.100.3 on eth1 [bgp_peer2 2024-01-02] (100) [AS64502?]
+	Type: BGP unicast univ
+	BGP.origin: Incomplete
+	BGP.as_path: 64502 {64503 64504}
+	BGP.next_hop: 198.51.100.3
+	BGP.local_pref: 90
+198.51.100.0/24    dev eth0 [direct1 2024-01-01] * (240)
+	Type: device unicast univ
+203.0.113.0/24     multipath [ospf1 2024-01-01] * E2 (150/10/10000) [198.51.100.9]
+	via 198.51.100.4 on eth0 weight 1
+	via 198.51.100.5 on eth1 weight 2
+	Type: OSPF-E2 unicast univ
+`
+
+const bird2Output = `Table master4:
+192.0.2.0/24         unicast [bgp_peer1 2024-01-01 from 198.51.100.2] * (100) [AS64500i]
+	via 198.51.100.1 on eth0
+	Type: BGP univ
+	BGP.origin: IGP
+	BGP.as_path: 64500 64501
+	BGP.next_hop: 198.51.100.1
+	BGP.med: 10
+	BGP.local_pref: 100
+	BGP.community: (64500,1) (64500,2) (64500,3) (64500,4) (64500,5) (64500,6)
+		(64500,7) (64500,8)
+	BGP.large_community: (64500, 1, 2) (64500, 3,
+		4)
+	BGP.ext_community: (rt, 64500, 1) (ro, 64500, 2)
+                     unicast [bgp_peer2 12:00:00.000] (100) [AS64502i]
+	via 198.51.100.3 on eth1
+	Type: BGP univ
+	BGP.origin: IGP
+	BGP.as_path: 64502
+	BGP.next_hop: 198.51.100.3
+	BGP.local_pref: 100
+192.0.2.128/25       unreachable [static1 2024-01-01] * (200)
+	Type: static univ
+`
+
+const bird3Output = `Table master6:
+2001:db8::/32        unicast [bgp_peer1 2024-01-01 from 2001:db8:ffff::2] * (100) [AS64500i]
+	via 2001:db8:ffff::1 on eth0
+	Preference: 100
+	Source: BGP
+	bgp_origin: IGP
+	bgp_path: 64500 64501
+	bgp_next_hop: 2001:db8:ffff::1 fe80::1
+	bgp_local_pref: 100
+	bgp_community: (64500,1)
+	bgp_large_community: (64500, 1, 2)
+	Internal route handling values: 0L 1G 0S id 1
+`
+
+func TestParseBird16(t *testing.T) {
+	routes := Parse(bird16Output)
+	if len(routes) != 4 {
+		t.Fatalf("expected 4 routes, got %d", len(routes))
+	}
+
+	r := routes[0]
+	if r.Prefix != "192.0.2.0/24" || r.Protocol != "bgp_peer1" || !r.Primary || r.Preference != 100 {
+		t.Fatalf("unexpected route header: %+v", r)
+	}
+	if r.From != "198.51.100.2" || r.Age != "2024-01-01" || r.OriginAS != 64500 {
+		t.Fatalf("unexpected route header: %+v", r)
+	}
+	if len(r.NextHops) != 1 || r.NextHops[0].Via != "198.51.100.1" || r.NextHops[0].Interface != "eth0" {
+		t.Fatalf("unexpected next hops: %+v", r.NextHops)
+	}
+	if r.BGP == nil || r.BGP.Origin != "IGP" || len(r.BGP.ASPath) != 2 || *r.BGP.MED != 10 || *r.BGP.LocalPref != 100 {
+		t.Fatalf("unexpected BGP attributes: %+v", r.BGP)
+	}
+	if len(r.BGP.Communities) != 2 || r.BGP.Communities[1] != (Community{64500, 2}) {
+		t.Fatalf("unexpected communities: %+v", r.BGP.Communities)
+	}
+	if len(r.BGP.LargeCommunities) != 1 || r.BGP.LargeCommunities[0] != (LargeCommunity{64500, 1, 2}) {
+		t.Fatalf("unexpected large communities: %+v", r.BGP.LargeCommunities)
+	}
+
+	r = routes[1]
+	if r.Prefix != "192.0.2.0/24" || r.Primary || r.Protocol != "bgp_peer2" {
+		t.Fatalf("secondary route not parsed: %+v", r)
+	}
+	if len(r.BGP.ASPath) != 3 || r.BGP.MED != nil {
+		t.Fatalf("unexpected BGP attributes: %+v", r.BGP)
+	}
+
+	r = routes[2]
+	if r.Prefix != "198.51.100.0/24" || r.NextHops[0].Interface != "eth0" || r.BGP != nil {
+		t.Fatalf("device route not parsed: %+v", r)
+	}
+
+	r = routes[3]
+	if r.Type != "multipath" || len(r.NextHops) != 2 || r.NextHops[1].Weight != 2 || r.Preference != 150 {
+		t.Fatalf("multipath route not parsed: %+v", r)
+	}
+}
+
+func TestParseBird2(t *testing.T) {
+	routes := Parse(bird2Output)
+	if len(routes) != 3 {
+		t.Fatalf("expected 3 routes, got %d", len(routes))
+	}
+
+	r := routes[0]
+	if r.Table != "master4" || r.Type != "unicast" || r.Attributes["Type"] != "BGP univ" {
+		t.Fatalf("unexpected route: %+v", r)
+	}
+	if len(r.NextHops) != 1 || r.NextHops[0].Via != "198.51.100.1" {
+		t.Fatalf("unexpected next hops: %+v", r.NextHops)
+	}
+	if len(r.BGP.Communities) != 8 {
+		t.Fatalf("wrapped communities not joined: %+v", r.BGP.Communities)
+	}
+	if len(r.BGP.LargeCommunities) != 2 || r.BGP.LargeCommunities[1] != (LargeCommunity{64500, 3, 4}) {
+		t.Fatalf("wrapped large communities not joined: %+v", r.BGP.LargeCommunities)
+	}
+	if len(r.BGP.ExtCommunities) != 2 {
+		t.Fatalf("unexpected ext communities: %+v", r.BGP.ExtCommunities)
+	}
+
+	r = routes[1]
+	if r.Prefix != "192.0.2.0/24" || r.Primary || r.Age != "12:00:00.000" || r.Table != "master4" {
+		t.Fatalf("secondary route not parsed: %+v", r)
+	}
+
+	r = routes[2]
+	if r.Prefix != "192.0.2.128/25" || r.Type != "unreachable" || r.Preference != 200 {
+		t.Fatalf("unreachable route not parsed: %+v", r)
+	}
+}
+
+func TestParseBird3(t *testing.T) {
+	routes := Parse(bird3Output)
+	if len(routes) != 1 {
+		t.Fatalf("expected 1 route, got %d", len(routes))
+	}
+
+	r := routes[0]
+	if r.Prefix != "2001:db8::/32" || r.Table != "master6" || r.From != "2001:db8:ffff::2" {
+		t.Fatalf("unexpected route: %+v", r)
+	}
+	if r.NextHops[0].Via != "2001:db8:ffff::1" {
+		t.Fatalf("unexpected next hops: %+v", r.NextHops)
+	}
+	if r.Attributes["Source"] != "BGP" || r.Attributes["Internal route handling values"] == "" {
+		t.Fatalf("unexpected attributes: %+v", r.Attributes)
+	}
+	if r.BGP.All["as_path"] != "64500 64501" || len(r.BGP.NextHop) != 2 || *r.BGP.LocalPref != 100 {
+		t.Fatalf("unexpected BGP attributes: %+v", r.BGP)
+	}
+	if len(r.BGP.Communities) != 1 || len(r.BGP.LargeCommunities) != 1 {
+		t.Fatalf("unexpected communities: %+v", r.BGP)
+	}
+}
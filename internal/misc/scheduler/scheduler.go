@@ -0,0 +1,230 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	ErrQueueFull    = errors.New("too many measurements are waiting")
+	ErrQueueTimeout = errors.New("timed out waiting for a measurement slot")
+)
+
+// Rejection reasons counted in Stats
+const (
+	RejectQueueFull    = "queue_full"
+	RejectQueueTimeout = "queue_timeout"
+	RejectCanceled     = "canceled"
+)
+
+// maxProbeID is the largest ICMP echo ID handed to jobs
+const maxProbeID = 65500
+
+// Limits bound the measurements running at once. Jobs beyond them wait in
+// a queue of at most MaxQueue jobs for up to MaxWait.
+type Limits struct {
+	MaxJobs       int
+	MaxJobsPerKey int
+	MaxQueue      int
+	MaxWait       time.Duration
+}
+
+// Validate checks that the limits let jobs run. MaxQueue may be zero to
+// reject jobs beyond the limits instead of queueing them.
+func (l Limits) Validate() error {
+	switch {
+	case l.MaxJobs <= 0 || l.MaxJobs > maxProbeID:
+		return fmt.Errorf("max_jobs must be between 1 and %d", maxProbeID)
+	case l.MaxJobsPerKey <= 0:
+		return errors.New("max_jobs_per_key must be positive")
+	case l.MaxQueue < 0:
+		return errors.New("max_queue must not be negative")
+	case l.MaxWait <= 0:
+		return errors.New("max_wait must be positive")
+	}
+	return nil
+}
+
+// Stats describe the current load of a scheduler
+type Stats struct {
+	Running    int
+	Queued     int
+	Rejections map[string]uint64
+}
+
+// Job is a running measurement. ProbeID is an ICMP echo ID no other
+// running job uses, so replies cannot be mixed up between jobs.
+type Job struct {
+	ProbeID int
+
+	s    *Scheduler
+	key  string
+	once sync.Once
+}
+
+type waiter struct {
+	key   string
+	ready chan *Job
+}
+
+// Scheduler limits concurrent measurements globally and per requesting
+// key. Waiting jobs are started in order, skipping those whose key is at
+// its limit. Every queued job is one that cannot start yet, so a new job
+// that fits the limits starts right away.
+type Scheduler struct {
+	limits Limits
+
+	mu         sync.Mutex
+	running    int
+	perKey     map[string]int
+	probeIDs   map[int]bool
+	nextID     int
+	queue      []*waiter
+	rejections map[string]uint64
+}
+
+// New returns a scheduler with the given limits
+func New(limits Limits) *Scheduler {
+	return &Scheduler{
+		limits:     limits,
+		perKey:     make(map[string]int),
+		probeIDs:   make(map[int]bool),
+		rejections: make(map[string]uint64),
+	}
+}
+
+// Limits returns the limits of the scheduler
+func (s *Scheduler) Limits() Limits {
+	return s.limits
+}
+
+// canStart reports whether a job of key fits the limits. s.mu is held.
+func (s *Scheduler) canStart(key string) bool {
+	return s.running < s.limits.MaxJobs && s.perKey[key] < s.limits.MaxJobsPerKey
+}
+
+// start runs a job of key. s.mu is held.
+func (s *Scheduler) start(key string) *Job {
+	s.running++
+	s.perKey[key]++
+
+	id := s.nextID
+	for {
+		id = id%maxProbeID + 1
+		if !s.probeIDs[id] {
+			break
+		}
+	}
+	s.nextID = id
+	s.probeIDs[id] = true
+	return &Job{ProbeID: id, s: s, key: key}
+}
+
+// Acquire starts a job for key, waiting in the queue if the limits are
+// reached. The job must be released when the measurement is done.
+func (s *Scheduler) Acquire(ctx context.Context, key string) (*Job, error) {
+	s.mu.Lock()
+	if s.canStart(key) {
+		job := s.start(key)
+		s.mu.Unlock()
+		return job, nil
+	}
+	if len(s.queue) >= s.limits.MaxQueue {
+		s.rejections[RejectQueueFull]++
+		s.mu.Unlock()
+		return nil, ErrQueueFull
+	}
+	w := &waiter{key: key, ready: make(chan *Job, 1)}
+	s.queue = append(s.queue, w)
+	s.mu.Unlock()
+
+	timer := time.NewTimer(s.limits.MaxWait)
+	defer timer.Stop()
+
+	var err error
+	reason := ""
+	select {
+	case job := <-w.ready:
+		return job, nil
+	case <-timer.C:
+		err, reason = ErrQueueTimeout, RejectQueueTimeout
+	case <-ctx.Done():
+		err, reason = ctx.Err(), RejectCanceled
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.dequeue(w) {
+		// The job was started while giving up, hand the slot on
+		job := <-w.ready
+		s.finish(job)
+	}
+	s.rejections[reason]++
+	return nil, err
+}
+
+// dequeue removes a waiter from the queue. It returns false if it was
+// already started. s.mu is held.
+func (s *Scheduler) dequeue(w *waiter) bool {
+	for i, q := range s.queue {
+		if q == w {
+			s.queue = append(s.queue[:i], s.queue[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// finish ends a job and starts the waiting jobs that fit now. s.mu is
+// held.
+func (s *Scheduler) finish(job *Job) {
+	s.running--
+	s.perKey[job.key]--
+	if s.perKey[job.key] == 0 {
+		delete(s.perKey, job.key)
+	}
+	delete(s.probeIDs, job.ProbeID)
+	s.dispatch()
+}
+
+// dispatch starts the waiting jobs that fit the limits, in order. s.mu is
+// held.
+func (s *Scheduler) dispatch() {
+	queue := s.queue[:0]
+	for _, w := range s.queue {
+		if s.canStart(w.key) {
+			w.ready <- s.start(w.key)
+			continue
+		}
+		queue = append(queue, w)
+	}
+	s.queue = queue
+}
+
+// Release ends the job. Further calls do nothing.
+func (j *Job) Release() {
+	j.once.Do(func() {
+		j.s.mu.Lock()
+		defer j.s.mu.Unlock()
+		j.s.finish(j)
+	})
+}
+
+// Stats returns the current load and the rejections so far
+func (s *Scheduler) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rejections := make(map[string]uint64, len(s.rejections))
+	for reason, n := range s.rejections {
+		rejections[reason] = n
+	}
+	return Stats{
+		Running:    s.running,
+		Queued:     len(s.queue),
+		Rejections: rejections,
+	}
+}
@@ -0,0 +1,152 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLimits(t *testing.T) {
+	s := New(Limits{MaxJobs: 2, MaxJobsPerKey: 1, MaxQueue: 1, MaxWait: time.Second})
+	ctx := context.Background()
+
+	a, err := s.Acquire(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := s.Acquire(ctx, "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.ProbeID == b.ProbeID {
+		t.Errorf("jobs share probe ID %d", a.ProbeID)
+	}
+
+	// A second job of a waits for the first one
+	started := make(chan *Job)
+	go func() {
+		job, err := s.Acquire(ctx, "a")
+		if err != nil {
+			t.Error(err)
+		}
+		started <- job
+	}()
+	for s.Stats().Queued != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	if _, err := s.Acquire(ctx, "c"); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("Acquire with a full queue: %v", err)
+	}
+
+	b.Release()
+	b.Release()
+	select {
+	case <-started:
+		t.Fatal("job of a started while a was still running")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	a.Release()
+	job := <-started
+	job.Release()
+
+	stats := s.Stats()
+	if stats.Running != 0 || stats.Queued != 0 || stats.Rejections[RejectQueueFull] != 1 {
+		t.Errorf("Stats() = %+v", stats)
+	}
+}
+
+func TestWait(t *testing.T) {
+	s := New(Limits{MaxJobs: 1, MaxJobsPerKey: 1, MaxQueue: 4, MaxWait: 10 * time.Millisecond})
+	job, err := s.Acquire(context.Background(), "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Acquire(context.Background(), "b"); !errors.Is(err, ErrQueueTimeout) {
+		t.Errorf("Acquire past MaxWait: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := s.Acquire(ctx, "b"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Acquire with canceled context: %v", err)
+	}
+
+	job.Release()
+	stats := s.Stats()
+	if stats.Running != 0 || stats.Queued != 0 ||
+		stats.Rejections[RejectQueueTimeout] != 1 || stats.Rejections[RejectCanceled] != 1 {
+		t.Errorf("Stats() = %+v", stats)
+	}
+	if _, err := s.Acquire(context.Background(), "b"); err != nil {
+		t.Errorf("Acquire after release: %v", err)
+	}
+}
+
+func TestSkipKeyAtLimit(t *testing.T) {
+	s := New(Limits{MaxJobs: 3, MaxJobsPerKey: 1, MaxQueue: 1, MaxWait: time.Second})
+	ctx := context.Background()
+
+	a, err := s.Acquire(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The second job of a waits for its key, not for a global slot
+	started := make(chan *Job)
+	go func() {
+		job, err := s.Acquire(ctx, "a")
+		if err != nil {
+			t.Error(err)
+		}
+		started <- job
+	}()
+	for s.Stats().Queued != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// b fits the limits, so it neither waits behind a nor finds the queue
+	// full
+	b, err := s.Acquire(ctx, "b")
+	if err != nil {
+		t.Fatalf("Acquire of a key below its limit: %v", err)
+	}
+	if stats := s.Stats(); stats.Running != 2 || stats.Queued != 1 {
+		t.Errorf("Stats() = %+v", stats)
+	}
+
+	b.Release()
+	a.Release()
+	job := <-started
+	job.Release()
+	if stats := s.Stats(); stats.Running != 0 || stats.Queued != 0 {
+		t.Errorf("Stats() = %+v", stats)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := Limits{MaxJobs: 4, MaxJobsPerKey: 2, MaxQueue: 16, MaxWait: time.Second}
+	cases := []struct {
+		name   string
+		modify func(l *Limits)
+		ok     bool
+	}{
+		{"defaults", func(l *Limits) {}, true},
+		{"no queue", func(l *Limits) { l.MaxQueue = 0 }, true},
+		{"no jobs", func(l *Limits) { l.MaxJobs = 0 }, false},
+		{"more jobs than probe IDs", func(l *Limits) { l.MaxJobs = maxProbeID + 1 }, false},
+		{"no jobs per key", func(l *Limits) { l.MaxJobsPerKey = 0 }, false},
+		{"negative queue", func(l *Limits) { l.MaxQueue = -1 }, false},
+		{"no wait", func(l *Limits) { l.MaxWait = 0 }, false},
+		{"negative wait", func(l *Limits) { l.MaxWait = -time.Second }, false},
+	}
+	for _, c := range cases {
+		l := valid
+		c.modify(&l)
+		if err := l.Validate(); (err == nil) != c.ok {
+			t.Errorf("%s: Validate() = %v", c.name, err)
+		}
+	}
+}
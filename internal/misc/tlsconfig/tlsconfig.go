@@ -0,0 +1,198 @@
+// Package tlsconfig builds the TLS configurations of the proxy listener
+// and of the frontend's proxy client.
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/LaunchPad-Network/NetPeek/constant"
+	"github.com/LaunchPad-Network/NetPeek/internal/logger"
+)
+
+var log = logger.New("TLS")
+
+// loadCertPool reads PEM certificates to trust from a file
+func loadCertPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("%s: no PEM certificates found", path)
+	}
+	return pool, nil
+}
+
+// ServerOptions are the files the proxy serves TLS with
+type ServerOptions struct {
+	CertFile string
+	KeyFile  string
+	// ClientCAFile requires client certificates issued by these CAs
+	ClientCAFile string
+}
+
+// reloader serves the certificate and client CAs read from files and
+// reads them again after they changed on disk
+type reloader struct {
+	opts     ServerOptions
+	interval time.Duration
+
+	mu        sync.Mutex
+	checked   time.Time
+	files     []os.FileInfo
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+}
+
+// stat returns the state of the files
+func (r *reloader) stat() ([]os.FileInfo, error) {
+	var files []os.FileInfo
+	for _, path := range []string{r.opts.CertFile, r.opts.KeyFile, r.opts.ClientCAFile} {
+		if path == "" {
+			continue
+		}
+		st, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, st)
+	}
+	return files, nil
+}
+
+// changed reports whether any file differs from its earlier state. Files
+// replaced by older copies, e.g. with cp -p or by swapping a symlink, have
+// an earlier modification time, so any difference counts.
+func changed(before, after []os.FileInfo) bool {
+	if len(before) != len(after) {
+		return true
+	}
+	for i := range before {
+		if !os.SameFile(before[i], after[i]) ||
+			!before[i].ModTime().Equal(after[i].ModTime()) ||
+			before[i].Size() != after[i].Size() {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *reloader) load() error {
+	cert, err := tls.LoadX509KeyPair(r.opts.CertFile, r.opts.KeyFile)
+	if err != nil {
+		return err
+	}
+	var clientCAs *x509.CertPool
+	if r.opts.ClientCAFile != "" {
+		clientCAs, err = loadCertPool(r.opts.ClientCAFile)
+		if err != nil {
+			return err
+		}
+	}
+	r.cert = &cert
+	r.clientCAs = clientCAs
+	return nil
+}
+
+// current returns the certificate and client CAs, checking the files for
+// changes at most once per interval. A failed reload keeps the previous
+// files in use.
+func (r *reloader) current() (*tls.Certificate, *x509.CertPool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(r.checked) < r.interval {
+		return r.cert, r.clientCAs
+	}
+	r.checked = now
+
+	files, err := r.stat()
+	if err != nil {
+		log.Errorf("Failed to check TLS certificate: %v", err)
+		return r.cert, r.clientCAs
+	}
+	if !changed(r.files, files) {
+		return r.cert, r.clientCAs
+	}
+	if err := r.load(); err != nil {
+		log.Errorf("Failed to reload TLS certificate, keeping the previous one: %v", err)
+		return r.cert, r.clientCAs
+	}
+	r.files = files
+	log.Infof("Loaded TLS certificate %s", r.opts.CertFile)
+	return r.cert, r.clientCAs
+}
+
+// Server returns the configuration of a TLS listener. The certificate and
+// client CAs are reloaded when their files change.
+func Server(opts ServerOptions) (*tls.Config, error) {
+	return server(opts, constant.TLSReloadCheckInterval)
+}
+
+func server(opts ServerOptions, interval time.Duration) (*tls.Config, error) {
+	r := &reloader{opts: opts, interval: interval}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	r.files, _ = r.stat()
+	r.checked = time.Now()
+
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			cert, clientCAs := r.current()
+			cfg := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*cert},
+				NextProtos:   []string{"h2", "http/1.1"},
+			}
+			if clientCAs != nil {
+				cfg.ClientCAs = clientCAs
+				cfg.ClientAuth = tls.RequireAndVerifyClientCert
+			}
+			return cfg, nil
+		},
+	}, nil
+}
+
+// ClientOptions are the files the frontend connects to proxies with
+type ClientOptions struct {
+	// CAFile pins the CAs proxy certificates must be issued by instead
+	// of the system roots
+	CAFile string
+	// CertFile and KeyFile are the client certificate for mutual TLS
+	CertFile string
+	KeyFile  string
+	// ServerName overrides the name verified and sent as SNI
+	ServerName string
+}
+
+// Client returns the configuration of connections to a proxy
+func Client(opts ClientOptions) (*tls.Config, error) {
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: opts.ServerName,
+	}
+	if opts.CAFile != "" {
+		pool, err := loadCertPool(opts.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = pool
+	}
+	if opts.CertFile != "" || opts.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
@@ -0,0 +1,201 @@
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCA issues self-signed certificates for tests
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+func newTestCA(t *testing.T, name string) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key, der: der}
+}
+
+// issue writes a certificate for name and its key to dir
+func (ca *testCA) issue(t *testing.T, dir, name string, serial int64) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+
+	certFile = filepath.Join(dir, name+".crt")
+	keyFile = filepath.Join(dir, name+".key")
+	writePEM(t, certFile, "CERTIFICATE", der)
+	writePEM(t, keyFile, "EC PRIVATE KEY", keyDER)
+	return certFile, keyFile
+}
+
+func (ca *testCA) write(t *testing.T, path string) string {
+	writePEM(t, path, "CERTIFICATE", ca.der)
+	return path
+}
+
+func writePEM(t *testing.T, path, typ string, der []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func startServer(t *testing.T, cfg *tls.Config) *httptest.Server {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	srv.TLS = cfg
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func get(t *testing.T, srv *httptest.Server, opts ClientOptions) (*http.Response, error) {
+	t.Helper()
+	cfg, err := Client(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: cfg}}
+	return client.Get(srv.URL)
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, "proxy CA")
+	caFile := ca.write(t, filepath.Join(dir, "ca.crt"))
+	serverCert, serverKey := ca.issue(t, dir, "node1.example.com", 2)
+	clientCert, clientKey := ca.issue(t, dir, "frontend", 3)
+
+	cfg, err := Server(ServerOptions{CertFile: serverCert, KeyFile: serverKey, ClientCAFile: caFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := startServer(t, cfg)
+
+	resp, err := get(t, srv, ClientOptions{
+		CAFile:     caFile,
+		CertFile:   clientCert,
+		KeyFile:    clientKey,
+		ServerName: "node1.example.com",
+	})
+	if err != nil {
+		t.Fatalf("mutual TLS request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp, err := get(t, srv, ClientOptions{CAFile: caFile, ServerName: "node1.example.com"}); err == nil {
+		resp.Body.Close()
+		t.Error("request without client certificate succeeded")
+	}
+	if resp, err := get(t, srv, ClientOptions{CertFile: clientCert, KeyFile: clientKey, ServerName: "node1.example.com"}); err == nil {
+		resp.Body.Close()
+		t.Error("request without pinned CA succeeded")
+	}
+	if resp, err := get(t, srv, ClientOptions{CAFile: caFile, CertFile: clientCert, KeyFile: clientKey, ServerName: "node2.example.com"}); err == nil {
+		resp.Body.Close()
+		t.Error("request with wrong server name succeeded")
+	}
+}
+
+func TestReload(t *testing.T) {
+	cases := []struct {
+		name  string
+		mtime time.Duration
+	}{
+		{"later modification time", time.Minute},
+		{"earlier modification time", -time.Hour},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			testReload(t, c.mtime)
+		})
+	}
+}
+
+// testReload rotates the certificate in place, moving the modification
+// time of the new files by mtime
+func testReload(t *testing.T, mtime time.Duration) {
+	dir := t.TempDir()
+	oldCA := newTestCA(t, "old CA")
+	certFile, keyFile := oldCA.issue(t, dir, "node1.example.com", 2)
+
+	cfg, err := server(ServerOptions{CertFile: certFile, KeyFile: keyFile}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := startServer(t, cfg)
+
+	newCA := newTestCA(t, "new CA")
+	newCAFile := newCA.write(t, filepath.Join(dir, "new-ca.crt"))
+	opts := ClientOptions{CAFile: newCAFile, ServerName: "node1.example.com"}
+	if resp, err := get(t, srv, opts); err == nil {
+		resp.Body.Close()
+		t.Fatal("certificate of the new CA served before rotation")
+	}
+
+	newCert, newKey := newCA.issue(t, t.TempDir(), "node1.example.com", 3)
+	for _, f := range [][2]string{{newCert, certFile}, {newKey, keyFile}} {
+		if err := os.Rename(f[0], f[1]); err != nil {
+			t.Fatal(err)
+		}
+		mod := time.Now().Add(mtime)
+		os.Chtimes(f[1], mod, mod)
+	}
+
+	resp, err := get(t, srv, opts)
+	if err != nil {
+		t.Fatalf("rotated certificate not served: %v", err)
+	}
+	resp.Body.Close()
+}
@@ -0,0 +1,22 @@
+//go:build linux
+
+package traceroute
+
+import "syscall"
+
+// bindDevice binds a socket to the named interface, so its packets leave
+// through it whichever route the kernel would pick. An empty name leaves
+// the socket unbound.
+func bindDevice(c syscall.RawConn, name string) error {
+	if name == "" {
+		return nil
+	}
+	var sockErr error
+	err := c.Control(func(fd uintptr) {
+		sockErr = syscall.SetsockoptString(int(fd), syscall.SOL_SOCKET, syscall.SO_BINDTODEVICE, name)
+	})
+	if err != nil {
+		return err
+	}
+	return sockErr
+}
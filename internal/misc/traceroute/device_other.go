@@ -0,0 +1,14 @@
+//go:build !linux
+
+package traceroute
+
+import "syscall"
+
+// bindDevice is not available here, so sources with an interface cannot
+// send probes
+func bindDevice(c syscall.RawConn, name string) error {
+	if name == "" {
+		return nil
+	}
+	return ErrNotSupported
+}
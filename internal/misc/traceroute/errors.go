@@ -6,4 +6,7 @@ var (
 	ErrEmptyTarget   = errors.New("empty target for traceroute")
 	ErrNotSupported  = errors.New("traceroute not supported")
 	ErrInvalidTarget = errors.New("invalid target")
+
+	ErrUnknownSource   = errors.New("unknown source")
+	ErrNoSourceAddress = errors.New("source has no address of the target's family")
 )
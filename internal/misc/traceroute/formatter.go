@@ -5,23 +5,14 @@ import (
 	"embed"
 	"fmt"
 	"html/template"
-	"net"
 	"strings"
 	"time"
 
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/proxyapi"
 	"github.com/syepes/network_exporter/pkg/common"
 	"github.com/syepes/network_exporter/pkg/mtr"
 )
 
-// PTR lookup
-func lookupPTR(ip string) string {
-	names, err := net.LookupAddr(ip)
-	if err != nil || len(names) == 0 {
-		return ""
-	}
-	return strings.TrimSuffix(names[0], ".")
-}
-
 // padRight pads string to fixed width (rune-safe)
 func padRight(s string, width int) string {
 	r := []rune(s)
@@ -45,79 +36,94 @@ func splitLines(s string, width int) []string {
 	return lines
 }
 
-func formatString(out *mtr.MtrResult) string {
-	const (
-		ipWidth  = 39
-		ptrWidth = 48
-	)
+const (
+	ipWidth  = 39
+	asWidth  = 12
+	ptrWidth = 48
+)
+
+// formatASN returns the AS column of a hop
+func formatASN(asn uint32) string {
+	if asn == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("AS%d", asn)
+}
 
+// formatHeader returns the header of the text output
+func formatHeader(destAddr string, opts Options) string {
 	var buffer bytes.Buffer
 
+	probe := opts.Protocol
+	if opts.Port != 0 {
+		probe += fmt.Sprintf(" port %d", opts.Port)
+	}
 	buffer.WriteString(fmt.Sprintf(
-		"Start: %v, DestAddr: %v\n",
+		"Start: %v, DestAddr: %v, Probe: %v",
 		time.Now().Format("2006-01-02 15:04:05"),
-		out.DestAddr,
+		destAddr,
+		probe,
 	))
-
-	if len(out.Hops) == 0 {
-		buffer.WriteString("Expected at least one hop\n")
-		return buffer.String()
+	if opts.Source != "" {
+		buffer.WriteString(fmt.Sprintf(", Source: %v (%v)", opts.Source, opts.SourceAddress))
 	}
+	buffer.WriteString("\n")
 
-	// Header
 	buffer.WriteString(fmt.Sprintf(
-		"%-3s %-39s %10s%c %10s %10s %10s %10s %10s %-48s\n",
-		"", "IP", "Loss", '%', "Snt", "Last", "Avg", "Best", "Worst", "PTR",
+		"%-3s %-39s %-12s %10s%c %10s %10s %10s %10s %10s %-48s\n",
+		"", "IP", "AS", "Loss", '%', "Snt", "Last", "Avg", "Best", "Worst", "PTR",
 	))
 
-	var lastHop int
+	return buffer.String()
+}
 
-	for _, hop := range out.Hops {
-		if hop.Success {
-			ptr := lookupPTR(hop.AddressTo)
-			if ptr == "" {
-				ptr = "-"
-			}
-
-			ptrLines := splitLines(ptr, ptrWidth)
-
-			for i, line := range ptrLines {
-				if i == 0 {
-					buffer.WriteString(fmt.Sprintf(
-						"%-3d %-39s %10.1f%c %10d %10.2f %10.2f %10.2f %10.2f %-48s\n",
-						hop.TTL,
-						padRight(hop.AddressTo, ipWidth),
-						hop.Loss,
-						'%',
-						hop.Snt,
-						common.Time2Float(hop.LastTime),
-						common.Time2Float(hop.AvgTime),
-						common.Time2Float(hop.BestTime),
-						common.Time2Float(hop.WorstTime),
-						padRight(line, ptrWidth),
-					))
-				} else {
-					buffer.WriteString(fmt.Sprintf(
-						"%-3s %-39s %10s%c %10s %10s %10s %10s %10s %-48s\n",
-						"",
-						padRight(hop.AddressTo, ipWidth),
-						"", '%', "", "", "", "", "",
-						padRight(line, ptrWidth),
-					))
-				}
-			}
-
-			lastHop = hop.TTL
-		} else {
-			lastHop++
+// formatHop returns the text output lines of a single hop
+func formatHop(hop proxyapi.TracerouteHop) string {
+	var buffer bytes.Buffer
+
+	if !hop.Success {
+		buffer.WriteString(fmt.Sprintf(
+			"%-3d %-39s %-12s %10.1f%c %10d %10.2f %10.2f %10.2f %10.2f %-48s\n",
+			hop.Hop,
+			padRight("???", ipWidth),
+			padRight("-", asWidth),
+			100.0,
+			'%',
+			0, 0.0, 0.0, 0.0, 0.0,
+			padRight("???", ptrWidth),
+		))
+		return buffer.String()
+	}
+
+	ptr := hop.PTR
+	if ptr == "" {
+		ptr = "-"
+	}
+
+	for i, line := range splitLines(ptr, ptrWidth) {
+		if i == 0 {
 			buffer.WriteString(fmt.Sprintf(
-				"%-3d %-39s %10.1f%c %10d %10.2f %10.2f %10.2f %10.2f %-48s\n",
-				lastHop,
-				padRight("???", ipWidth),
-				100.0,
+				"%-3d %-39s %-12s %10.1f%c %10d %10.2f %10.2f %10.2f %10.2f %-48s\n",
+				hop.Hop,
+				padRight(hop.Address, ipWidth),
+				padRight(formatASN(hop.ASN), asWidth),
+				hop.Loss,
 				'%',
-				0, 0.0, 0.0, 0.0, 0.0,
-				padRight("???", ptrWidth),
+				hop.Sent,
+				hop.Last,
+				hop.Avg,
+				hop.Best,
+				hop.Worst,
+				padRight(line, ptrWidth),
+			))
+		} else {
+			buffer.WriteString(fmt.Sprintf(
+				"%-3s %-39s %-12s %10s%c %10s %10s %10s %10s %10s %-48s\n",
+				"",
+				padRight(hop.Address, ipWidth),
+				"",
+				"", '%', "", "", "", "", "",
+				padRight(line, ptrWidth),
 			))
 		}
 	}
@@ -125,84 +131,86 @@ func formatString(out *mtr.MtrResult) string {
 	return buffer.String()
 }
 
-type mtrHopView struct {
-	Hop     int
-	Host    string
-	Loss    float32
-	Snt     int
-	Last    float32
-	Avg     float32
-	Best    float32
-	Worst   float32
-	PTR     string
-	Success bool
-	IsFinal bool
+// newResult returns the document of a traceroute without hops
+func newResult(target, address string, opts Options, start time.Time) *proxyapi.TracerouteResult {
+	return &proxyapi.TracerouteResult{
+		Version: proxyapi.TracerouteVersion,
+		Target:  target,
+		Address: address,
+		Start:   start,
+		Probe: proxyapi.TracerouteProbe{
+			Protocol: opts.Protocol,
+			Port:     opts.Port,
+			MaxHops:  opts.MaxHops,
+			Count:    opts.Count,
+			Timeout:  int(opts.Timeout.Milliseconds()),
+			Size:     opts.Size,
+
+			Source:        opts.Source,
+			SourceAddress: opts.SourceAddress,
+		},
+		Hops: []proxyapi.TracerouteHop{},
+	}
 }
 
-type mtrView struct {
-	Start    string
-	DestAddr string
-	Hops     []mtrHopView
-}
+// documentHop turns a measured hop into a hop of the document, with the
+// reverse DNS name ptr and the origin of its address
+func documentHop(hop common.IcmpHop, ptr string, org origin) proxyapi.TracerouteHop {
+	if !hop.Success {
+		return proxyapi.TracerouteHop{Hop: hop.TTL, Loss: 100}
+	}
 
-func buildMtrView(out *mtr.MtrResult) *mtrView {
-	view := &mtrView{
-		Start:    time.Now().Format("2006-01-02 15:04:05"),
-		DestAddr: out.DestAddr,
-		Hops:     make([]mtrHopView, 0, len(out.Hops)),
-	}
-
-	for index, hop := range out.Hops {
-		hopView := mtrHopView{
-			Hop:     hop.TTL,
-			Host:    hop.AddressTo,
-			Loss:    float32(hop.Loss),
-			Snt:     hop.Snt,
-			Last:    common.Time2Float(hop.LastTime),
-			Avg:     common.Time2Float(hop.AvgTime),
-			Best:    common.Time2Float(hop.BestTime),
-			Worst:   common.Time2Float(hop.WorstTime),
-			Success: hop.Success,
-			IsFinal: false,
-		}
+	return proxyapi.TracerouteHop{
+		Hop:     hop.TTL,
+		Address: hop.AddressTo,
+		PTR:     ptr,
+		Prefix:  org.Prefix,
+		ASN:     org.ASN,
+		Success: true,
+		Loss:    hop.Loss,
+		Sent:    hop.Snt,
+		Last:    milliseconds(hop.LastTime),
+		Avg:     milliseconds(hop.AvgTime),
+		Best:    milliseconds(hop.BestTime),
+		Worst:   milliseconds(hop.WorstTime),
+	}
+}
 
-		if !hop.Success {
-			hopView.Host = "???"
-			hopView.Loss = 100
-			hopView.Snt = 0
-			hopView.Last = 0
-			hopView.Avg = 0
-			hopView.Best = 0
-			hopView.Worst = 0
-			hopView.PTR = ""
-
-			if index == len(out.Hops)-1 {
-				hopView.IsFinal = true
-			}
-		} else {
-			ptr := lookupPTR(hop.AddressTo)
-			hopView.PTR = ptr
+// buildResult turns the hops of a traceroute into the document sent to
+// frontends
+func buildResult(target, address string, opts Options, start time.Time, out *mtr.MtrResult) *proxyapi.TracerouteResult {
+	var addrs []string
+	for _, hop := range out.Hops {
+		if hop.Success {
+			addrs = append(addrs, hop.AddressTo)
 		}
-
-		view.Hops = append(view.Hops, hopView)
 	}
+	ptrs, origins := lookupHops(addrs)
 
-	return view
+	res := newResult(target, address, opts, start)
+	res.End = time.Now()
+	for _, hop := range out.Hops {
+		res.Hops = append(res.Hops, documentHop(hop, ptrs[hop.AddressTo], origins[hop.AddressTo]))
+	}
+	if n := len(res.Hops); n > 0 && !res.Hops[n-1].Success {
+		res.Hops[n-1].Final = true
+	}
+	return res
 }
 
 //go:embed template.html
 var htmlTmplFS embed.FS
 
-func formatHTML(out *mtr.MtrResult) (string, error) {
-	view := buildMtrView(out)
-
+// formatHTML renders a traceroute as the HTML table of the legacy
+// /tracerouteh endpoint
+func formatHTML(res *proxyapi.TracerouteResult) (string, error) {
 	tmpl, err := template.ParseFS(htmlTmplFS, "template.html")
 	if err != nil {
 		return "", err
 	}
 
 	var buf bytes.Buffer
-	if err := tmpl.Execute(&buf, view); err != nil {
+	if err := tmpl.Execute(&buf, res); err != nil {
 		return "", err
 	}
 
@@ -0,0 +1,123 @@
+package traceroute
+
+import (
+	"sync"
+
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/proxyapi"
+	"github.com/syepes/network_exporter/pkg/common"
+)
+
+// forEachUnique calls fn once for every distinct item, running up to
+// workers calls at once, and returns when all calls are done
+func forEachUnique(items []string, workers int, fn func(item string)) {
+	var wg sync.WaitGroup
+	queue := make(chan string)
+	for i := 0; i < min(workers, len(items)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range queue {
+				fn(item)
+			}
+		}()
+	}
+
+	seen := make(map[string]bool, len(items))
+	for _, item := range items {
+		if !seen[item] {
+			seen[item] = true
+			queue <- item
+		}
+	}
+	close(queue)
+	wg.Wait()
+}
+
+// lookupHops resolves the reverse DNS names and origins of the addresses
+// of hops. Both kinds of lookups run at the same time.
+func lookupHops(addrs []string) (map[string]string, map[string]origin) {
+	var ptrs map[string]string
+	done := make(chan struct{})
+	go func() {
+		ptrs = lookupPTRs(addrs)
+		close(done)
+	}()
+	origins := lookupOrigins(addrs)
+	<-done
+	return ptrs, origins
+}
+
+// hopWriter passes streamed hops to write in the order they were
+// measured. Their names and origins are looked up in the background, so
+// the next hops are measured meanwhile.
+type hopWriter struct {
+	write   func(hop proxyapi.TracerouteHop) error
+	pending chan chan proxyapi.TracerouteHop
+	done    chan struct{}
+
+	mu  sync.Mutex
+	err error
+}
+
+// newHopWriter returns a hopWriter for up to maxHops hops
+func newHopWriter(maxHops int, write func(hop proxyapi.TracerouteHop) error) *hopWriter {
+	hw := &hopWriter{
+		write:   write,
+		pending: make(chan chan proxyapi.TracerouteHop, maxHops),
+		done:    make(chan struct{}),
+	}
+	go hw.run()
+	return hw
+}
+
+func (hw *hopWriter) run() {
+	defer close(hw.done)
+	for resolved := range hw.pending {
+		hop := <-resolved
+		if hw.Err() != nil {
+			continue
+		}
+		if err := hw.write(hop); err != nil {
+			hw.mu.Lock()
+			hw.err = err
+			hw.mu.Unlock()
+		}
+	}
+}
+
+// Add starts looking up the name and origin of hop and returns the error
+// of an earlier write, if any
+func (hw *hopWriter) Add(hop common.IcmpHop) error {
+	resolved := make(chan proxyapi.TracerouteHop, 1)
+	go func() {
+		resolved <- resolveHop(hop)
+	}()
+	hw.pending <- resolved
+	return hw.Err()
+}
+
+// Err returns the first error of write
+func (hw *hopWriter) Err() error {
+	hw.mu.Lock()
+	defer hw.mu.Unlock()
+	return hw.err
+}
+
+// Close waits until all hops are written and returns the first error of
+// write
+func (hw *hopWriter) Close() error {
+	close(hw.pending)
+	<-hw.done
+	return hw.Err()
+}
+
+// resolveHop turns a single measured hop into a hop of the document,
+// looking up the name and origin of its address
+func resolveHop(hop common.IcmpHop) proxyapi.TracerouteHop {
+	if !hop.Success {
+		return documentHop(hop, "", origin{})
+	}
+	addrs := []string{hop.AddressTo}
+	ptrs, origins := lookupHops(addrs)
+	return documentHop(hop, ptrs[hop.AddressTo], origins[hop.AddressTo])
+}
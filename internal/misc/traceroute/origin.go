@@ -0,0 +1,155 @@
+package traceroute
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/LaunchPad-Network/NetPeek/constant"
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/bird"
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/origintable"
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/routeparser"
+	"github.com/lfcypo/viperx"
+	"github.com/patrickmn/go-cache"
+	"github.com/spf13/viper"
+)
+
+// Sources of the origin AS and covering prefix of hops
+const (
+	OriginBird  = "bird"
+	OriginTable = "table"
+	OriginNone  = "none"
+)
+
+var originTable *origintable.Table
+
+// originSource is the configured source of hop origins
+func originSource() string {
+	return viperx.GetString("traceroute.origin", OriginBird)
+}
+
+// LoadOrigins loads the prefix-to-AS table if hop origins are looked up
+// in traceroute.origin_table
+func LoadOrigins() error {
+	switch originSource() {
+	case OriginBird, OriginNone:
+		return nil
+	case OriginTable:
+	default:
+		return fmt.Errorf("invalid traceroute.origin %q", originSource())
+	}
+
+	path := viper.GetString("traceroute.origin_table")
+	if path == "" {
+		return fmt.Errorf("traceroute.origin is %q but traceroute.origin_table is not set", OriginTable)
+	}
+	t, err := origintable.Load(path)
+	if err != nil {
+		return fmt.Errorf("failed to load traceroute.origin_table: %w", err)
+	}
+	originTable = t
+	return nil
+}
+
+// origin is the route covering an address and the AS originating it
+type origin struct {
+	Prefix string
+	ASN    uint32
+}
+
+// originCache holds BIRD lookups of all requests. Failed lookups are
+// cached as unknown origins for a shorter time.
+var originCache = cache.New(constant.TracerouteOriginCacheDuration, 10*time.Minute)
+
+// lookupOrigin returns the origin of a hop. Addresses that are not
+// globally routed are skipped.
+func lookupOrigin(ctx context.Context, addr string) origin {
+	ip, err := netip.ParseAddr(addr)
+	if err != nil || !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return origin{}
+	}
+
+	switch originSource() {
+	case OriginTable:
+		if originTable == nil {
+			return origin{}
+		}
+		prefix, asn, ok := originTable.Lookup(addr)
+		if !ok {
+			return origin{}
+		}
+		return origin{Prefix: prefix.String(), ASN: asn}
+	case OriginBird:
+		if v, found := originCache.Get(addr); found {
+			return v.(origin)
+		}
+		org, err := birdOrigin(ctx, ip)
+		if err != nil {
+			log.Debugf("origin lookup of %s failed: %v", ip, err)
+			originCache.Set(addr, origin{}, constant.TracerouteOriginFailedCacheDuration)
+			return origin{}
+		}
+		originCache.Set(addr, org, cache.DefaultExpiration)
+		return org
+	default:
+		return origin{}
+	}
+}
+
+// lookupOrigins looks up the origins of several addresses in parallel,
+// all within one deadline, and returns them by address
+func lookupOrigins(addrs []string) map[string]origin {
+	ctx, cancel := context.WithTimeout(context.Background(), constant.TracerouteOriginTimeout)
+	defer cancel()
+
+	origins := make(map[string]origin, len(addrs))
+	var mu sync.Mutex
+	forEachUnique(addrs, constant.TracerouteOriginWorkers, func(addr string) {
+		org := lookupOrigin(ctx, addr)
+		mu.Lock()
+		origins[addr] = org
+		mu.Unlock()
+	})
+	return origins
+}
+
+// birdOrigin looks up the best route to ip in the first BIRD instance of
+// its address family
+func birdOrigin(ctx context.Context, ip netip.Addr) (origin, error) {
+	var inst *bird.Instance
+	for _, i := range bird.Instances() {
+		if i.Serves(ip.Is6()) {
+			inst = i
+			break
+		}
+	}
+	if inst == nil {
+		return origin{}, nil
+	}
+
+	var out strings.Builder
+	if err := inst.Query(ctx, "show route for "+ip.String()+" all", true, &out); err != nil {
+		return origin{}, err
+	}
+
+	routes := routeparser.Parse(out.String())
+	if len(routes) == 0 {
+		return origin{}, nil
+	}
+	best := routes[0]
+	for _, r := range routes {
+		if r.Primary {
+			best = r
+			break
+		}
+	}
+
+	asn := best.OriginAS
+	if asn == 0 && best.BGP != nil && len(best.BGP.ASPath) > 0 {
+		asn = best.BGP.ASPath[len(best.BGP.ASPath)-1]
+	}
+	return origin{Prefix: best.Prefix, ASN: asn}, nil
+}
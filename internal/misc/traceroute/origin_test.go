@@ -0,0 +1,99 @@
+package traceroute
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/origintable"
+	"github.com/patrickmn/go-cache"
+	"github.com/spf13/viper"
+)
+
+func TestLookupOriginTable(t *testing.T) {
+	table, err := origintable.Parse(strings.NewReader(`192.0.2.0/24 64496
+10.0.0.0/8 64497
+2001:db8::/32 64498
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	originTable = table
+	viper.Set("traceroute.origin", OriginTable)
+	defer func() {
+		originTable = nil
+		viper.Set("traceroute.origin", nil)
+	}()
+
+	cases := []struct {
+		addr string
+		want origin
+	}{
+		{"192.0.2.1", origin{"192.0.2.0/24", 64496}},
+		{"2001:db8::1", origin{"2001:db8::/32", 64498}},
+		{"198.51.100.1", origin{}},
+		{"10.1.2.3", origin{}},
+		{"127.0.0.1", origin{}},
+		{"fe80::1", origin{}},
+		{"???", origin{}},
+	}
+	for _, c := range cases {
+		if got := lookupOrigin(context.Background(), c.addr); got != c.want {
+			t.Errorf("lookupOrigin(%q) = %+v, want %+v", c.addr, got, c.want)
+		}
+	}
+
+	origins := lookupOrigins([]string{"192.0.2.1", "2001:db8::1", "192.0.2.1"})
+	if len(origins) != 2 || origins["192.0.2.1"].ASN != 64496 || origins["2001:db8::1"].ASN != 64498 {
+		t.Errorf("lookupOrigins() = %+v", origins)
+	}
+}
+
+func TestLookupOriginCached(t *testing.T) {
+	viper.Set("traceroute.origin", OriginBird)
+	defer viper.Set("traceroute.origin", nil)
+
+	// Without BIRD instances, only cached origins are found
+	want := origin{"198.51.100.0/24", 64499}
+	originCache.Set("198.51.100.7", want, cache.DefaultExpiration)
+	defer originCache.Delete("198.51.100.7")
+
+	origins := lookupOrigins([]string{"198.51.100.7", "198.51.100.8"})
+	if origins["198.51.100.7"] != want {
+		t.Errorf("cached origin = %+v, want %+v", origins["198.51.100.7"], want)
+	}
+	if origins["198.51.100.8"] != (origin{}) {
+		t.Errorf("uncached origin = %+v", origins["198.51.100.8"])
+	}
+}
+
+func TestForEachUnique(t *testing.T) {
+	cases := []struct {
+		items   []string
+		workers int
+		want    int
+	}{
+		{nil, 4, 0},
+		{[]string{"a"}, 4, 1},
+		{[]string{"a", "b", "a", "c", "b"}, 2, 3},
+		{[]string{"a", "a", "a"}, 1, 1},
+	}
+	for _, c := range cases {
+		var mu sync.Mutex
+		calls := make(map[string]int)
+		forEachUnique(c.items, c.workers, func(item string) {
+			mu.Lock()
+			calls[item]++
+			mu.Unlock()
+		})
+		if len(calls) != c.want {
+			t.Errorf("forEachUnique(%v) called %d items, want %d", c.items, len(calls), c.want)
+		}
+		for item, n := range calls {
+			if n != 1 {
+				t.Errorf("forEachUnique(%v) called %q %d times", c.items, item, n)
+			}
+		}
+	}
+}
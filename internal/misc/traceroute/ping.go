@@ -0,0 +1,152 @@
+package traceroute
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/proxyapi"
+	"github.com/lfcypo/viperx"
+	"github.com/spf13/viper"
+)
+
+// PingOptions are the settings of a ping
+type PingOptions struct {
+	Count    int
+	Interval time.Duration
+	Timeout  time.Duration
+	Size     int
+}
+
+// PingLimits bound what a ping request may ask for
+type PingLimits struct {
+	MaxCount    int
+	MinInterval time.Duration
+	MaxSize     int
+}
+
+// PingEnabled reports whether ping is available on this proxy
+func PingEnabled() bool {
+	return !viper.GetBool("ping.disable")
+}
+
+// LoadPingOptions returns the configured default ping settings
+func LoadPingOptions() PingOptions {
+	return PingOptions{
+		Count:    viperx.GetInt("ping.count", 5),
+		Interval: time.Duration(viperx.GetInt("ping.interval", 1000)) * time.Millisecond,
+		Timeout:  time.Duration(viperx.GetInt("ping.timeout", 1)) * time.Second,
+		Size:     viperx.GetInt("ping.size", 56),
+	}
+}
+
+// LoadPingLimits returns the configured bounds of ping requests
+func LoadPingLimits() PingLimits {
+	return PingLimits{
+		MaxCount:    viperx.GetInt("ping.max_count", 10),
+		MinInterval: time.Duration(viperx.GetInt("ping.min_interval", 200)) * time.Millisecond,
+		MaxSize:     viperx.GetInt("ping.max_size", 1472),
+	}
+}
+
+// pingOptions applies the arguments of a request to the defaults, clamped
+// to the limits. Zero arguments keep the defaults.
+func pingOptions(args proxyapi.Args) PingOptions {
+	opts := LoadPingOptions()
+	limits := LoadPingLimits()
+
+	if args.Count > 0 {
+		opts.Count = args.Count
+	}
+	if args.Interval > 0 {
+		opts.Interval = time.Duration(args.Interval) * time.Millisecond
+	}
+	if args.Size > 0 {
+		opts.Size = args.Size
+	}
+
+	opts.Count = min(opts.Count, limits.MaxCount)
+	opts.Interval = max(opts.Interval, limits.MinInterval)
+	opts.Size = min(opts.Size, limits.MaxSize)
+	return opts
+}
+
+// Ping sends echo requests to the target of a ping request and returns
+// every probe and the statistics of the replies. probeID is the ICMP echo
+// ID, unique among running jobs. It stops early if ctx is done.
+func Ping(ctx context.Context, args proxyapi.Args, probeID int) (*proxyapi.PingResult, error) {
+	if !PingEnabled() {
+		return nil, ErrNotSupported
+	}
+	target, isV6, source, device, err := resolveSource(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := pingOptions(args)
+	res := &proxyapi.PingResult{
+		Target:        args.Target,
+		Address:       target,
+		Source:        args.Source,
+		SourceAddress: source,
+		Size:          opts.Size,
+		Probes:        make([]proxyapi.PingProbe, 0, opts.Count),
+	}
+	var times []time.Duration
+
+	for seq := 0; seq < opts.Count; seq++ {
+		if seq > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(opts.Interval):
+			}
+		}
+
+		probe := proxyapi.PingProbe{Seq: seq}
+		ret, err := probeICMP(target, source, device, isV6, 64, probeID, seq, opts.Timeout, opts.Size)
+		if err == nil && ret.Success {
+			probe.Success = true
+			probe.RTT = milliseconds(ret.Elapsed)
+			times = append(times, ret.Elapsed)
+		}
+		res.Probes = append(res.Probes, probe)
+	}
+
+	summarizePing(res, times)
+	return res, nil
+}
+
+// summarizePing fills in the loss and round-trip statistics of a ping
+func summarizePing(res *proxyapi.PingResult, times []time.Duration) {
+	res.Sent = len(res.Probes)
+	res.Received = len(times)
+	if res.Sent > 0 {
+		res.Loss = float64(res.Sent-res.Received) / float64(res.Sent) * 100
+	}
+	if len(times) == 0 {
+		return
+	}
+
+	var sum float64
+	res.Min = math.Inf(1)
+	for _, t := range times {
+		ms := milliseconds(t)
+		sum += ms
+		res.Min = min(res.Min, ms)
+		res.Max = max(res.Max, ms)
+	}
+	res.Avg = sum / float64(len(times))
+
+	var dev float64
+	for _, t := range times {
+		d := milliseconds(t) - res.Avg
+		dev += d * d
+	}
+	res.StdDev = math.Sqrt(dev / float64(len(times)))
+}
+
+// milliseconds converts a round-trip time to the unit of ping results
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,362 @@
+package traceroute
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/proxyapi"
+	"github.com/syepes/network_exporter/pkg/common"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// Protocols lists the probe protocols in the order they are offered
+var Protocols = []string{proxyapi.ProbeICMP, proxyapi.ProbeTCP, proxyapi.ProbeUDP}
+
+// IP protocol numbers in the headers quoted by ICMP errors
+const (
+	protoICMP   = 1
+	protoTCP    = 6
+	protoUDP    = 17
+	protoICMPv6 = 58
+)
+
+// DefaultPort is the destination port of TCP and UDP probes without one
+func DefaultPort(protocol string) int {
+	if protocol == proxyapi.ProbeUDP {
+		return 33434
+	}
+	return 80
+}
+
+// icmpReply is an ICMP error caused by one of our probes
+type icmpReply struct {
+	addr string
+	at   time.Time
+}
+
+// quotedHeader returns the protocol and transport header of the datagram
+// quoted by an ICMP error, whose data starts with the IP header of the
+// probe
+func quotedHeader(data []byte, isV6 bool, dst net.IP) (proto int, hdr []byte, ok bool) {
+	var hdrLen int
+	var quotedDst net.IP
+	if isV6 {
+		if len(data) < ipv6.HeaderLen {
+			return 0, nil, false
+		}
+		hdrLen = ipv6.HeaderLen
+		proto = int(data[6])
+		quotedDst = net.IP(data[24:40])
+	} else {
+		if len(data) < ipv4.HeaderLen {
+			return 0, nil, false
+		}
+		hdrLen = int(data[0]&0x0f) * 4
+		proto = int(data[9])
+		quotedDst = net.IP(data[16:20])
+	}
+	if len(data) < hdrLen || !quotedDst.Equal(dst) {
+		return 0, nil, false
+	}
+	return proto, data[hdrLen:], true
+}
+
+// quotedPorts returns the protocol and ports of the datagram quoted by an
+// ICMP error
+func quotedPorts(data []byte, isV6 bool, dst net.IP) (proto int, src, dstPort int, ok bool) {
+	proto, hdr, ok := quotedHeader(data, isV6, dst)
+	if !ok || len(hdr) < 4 {
+		return 0, 0, 0, false
+	}
+	src = int(binary.BigEndian.Uint16(hdr))
+	dstPort = int(binary.BigEndian.Uint16(hdr[2:]))
+	return proto, src, dstPort, true
+}
+
+// quotesProbe reports whether the datagram quoted by an ICMP error is a
+// probe of proto sent from localPort to port of dst
+func quotesProbe(data []byte, isV6 bool, dst net.IP, proto, localPort, port int) bool {
+	quotedProto, src, dstPort, ok := quotedPorts(data, isV6, dst)
+	return ok && quotedProto == proto && src == localPort && dstPort == port
+}
+
+// quotesEcho reports whether the datagram quoted by an ICMP error is an
+// echo request to dst with the given ID and sequence number
+func quotesEcho(data []byte, isV6 bool, dst net.IP, id, seq int) bool {
+	proto, hdr, ok := quotedHeader(data, isV6, dst)
+	want := protoICMP
+	if isV6 {
+		want = protoICMPv6
+	}
+	return ok && proto == want && len(hdr) >= 8 &&
+		int(binary.BigEndian.Uint16(hdr[4:])) == id&0xffff &&
+		int(binary.BigEndian.Uint16(hdr[6:])) == seq&0xffff
+}
+
+// quotedData returns the datagram quoted by a time exceeded or destination
+// unreachable message, nil for other messages
+func quotedData(msg *icmp.Message) []byte {
+	switch body := msg.Body.(type) {
+	case *icmp.TimeExceeded:
+		return body.Data
+	case *icmp.DstUnreach:
+		return body.Data
+	}
+	return nil
+}
+
+// listenICMP opens the socket ICMP errors about TCP and UDP probes
+// arrive on
+func listenICMP(isV6 bool) (*icmp.PacketConn, error) {
+	if isV6 {
+		return icmp.ListenPacket("ip6:ipv6-icmp", "::")
+	}
+	return icmp.ListenPacket("ip4:icmp", "0.0.0.0")
+}
+
+// readICMP waits until the deadline of conn for an ICMP message accepted
+// by match
+func readICMP(conn net.PacketConn, isV6 bool, match func(msg *icmp.Message) bool) (icmpReply, bool) {
+	proto := protoICMP
+	if isV6 {
+		proto = protoICMPv6
+	}
+	b := make([]byte, 1500)
+	for {
+		n, peer, err := conn.ReadFrom(b)
+		if err != nil {
+			return icmpReply{}, false
+		}
+		at := time.Now()
+		msg, err := icmp.ParseMessage(proto, b[:n])
+		if err != nil {
+			continue
+		}
+		if match(msg) {
+			host, _, _ := net.SplitHostPort(peer.String())
+			if host == "" {
+				host = peer.String()
+			}
+			return icmpReply{addr: host, at: at}, true
+		}
+	}
+}
+
+// probeICMP sends an echo request with the given TTL, ID and sequence
+// number from source to target, out of device if it is set. Routers answer
+// with time exceeded, the target with an echo reply.
+func probeICMP(target, source, device string, isV6 bool, ttl, id, seq int, timeout time.Duration, size int) (common.IcmpReturn, error) {
+	var hop common.IcmpReturn
+	dst := net.ParseIP(target)
+
+	network, local := "ip4:icmp", "0.0.0.0"
+	var request, reply icmp.Type = ipv4.ICMPTypeEcho, ipv4.ICMPTypeEchoReply
+	if isV6 {
+		network, local = "ip6:ipv6-icmp", "::"
+		request, reply = ipv6.ICMPTypeEchoRequest, ipv6.ICMPTypeEchoReply
+	}
+	if source != "" {
+		local = source
+	}
+	lc := net.ListenConfig{
+		Control: func(network, address string, c syscall.RawConn) error {
+			if err := bindDevice(c, device); err != nil {
+				return err
+			}
+			return setTTL(c, isV6, ttl)
+		},
+	}
+	conn, err := lc.ListenPacket(context.Background(), network, local)
+	if err != nil {
+		return hop, err
+	}
+	defer conn.Close()
+
+	// The kernel fills in the checksum of ICMPv6 messages
+	msg := icmp.Message{
+		Type: request,
+		Body: &icmp.Echo{ID: id & 0xffff, Seq: seq & 0xffff, Data: make([]byte, size)},
+	}
+	b, err := msg.Marshal(nil)
+	if err != nil {
+		return hop, err
+	}
+
+	start := time.Now()
+	if err := conn.SetReadDeadline(start.Add(timeout)); err != nil {
+		return hop, err
+	}
+	if _, err := conn.WriteTo(b, &net.IPAddr{IP: dst}); err != nil {
+		return hop, err
+	}
+
+	res, ok := readICMP(conn, isV6, func(msg *icmp.Message) bool {
+		if echo, isEcho := msg.Body.(*icmp.Echo); isEcho {
+			return msg.Type == reply && echo.ID == id&0xffff && echo.Seq == seq&0xffff
+		}
+		return quotesEcho(quotedData(msg), isV6, dst, id, seq)
+	})
+	if ok {
+		hop.Success = true
+		hop.Addr = res.addr
+		hop.Elapsed = res.at.Sub(start)
+	}
+	return hop, nil
+}
+
+// probeUDP sends a UDP datagram with the given TTL from source to the port
+// of target, out of device if it is set. Routers answer with time exceeded,
+// the target with port unreachable.
+func probeUDP(target, source, device string, isV6 bool, ttl, port int, timeout time.Duration, size int) (common.IcmpReturn, error) {
+	var hop common.IcmpReturn
+	dst := net.ParseIP(target)
+
+	icmpConn, err := listenICMP(isV6)
+	if err != nil {
+		return hop, err
+	}
+	defer icmpConn.Close()
+
+	network := "udp4"
+	if isV6 {
+		network = "udp6"
+	}
+	lc := net.ListenConfig{
+		Control: func(network, address string, c syscall.RawConn) error {
+			return bindDevice(c, device)
+		},
+	}
+	conn, err := lc.ListenPacket(context.Background(), network, net.JoinHostPort(source, "0"))
+	if err != nil {
+		return hop, err
+	}
+	defer conn.Close()
+	if isV6 {
+		err = ipv6.NewPacketConn(conn).SetHopLimit(ttl)
+	} else {
+		err = ipv4.NewPacketConn(conn).SetTTL(ttl)
+	}
+	if err != nil {
+		return hop, err
+	}
+	localPort := conn.LocalAddr().(*net.UDPAddr).Port
+
+	start := time.Now()
+	if err := icmpConn.SetReadDeadline(start.Add(timeout)); err != nil {
+		return hop, err
+	}
+	if _, err := conn.WriteTo(make([]byte, size), &net.UDPAddr{IP: dst, Port: port}); err != nil {
+		return hop, err
+	}
+
+	reply, ok := readICMP(icmpConn, isV6, func(msg *icmp.Message) bool {
+		return quotesProbe(quotedData(msg), isV6, dst, protoUDP, localPort, port)
+	})
+	if ok {
+		hop.Success = true
+		hop.Addr = reply.addr
+		hop.Elapsed = reply.at.Sub(start)
+	}
+	return hop, nil
+}
+
+// probeTCP opens a TCP connection with the given TTL from source to the
+// port of target, out of device if it is set. Routers answer the SYN with
+// time exceeded, the target with SYN-ACK or RST.
+func probeTCP(target, source, device string, isV6 bool, ttl, port int, timeout time.Duration) (common.IcmpReturn, error) {
+	var hop common.IcmpReturn
+	dst := net.ParseIP(target)
+
+	icmpConn, err := listenICMP(isV6)
+	if err != nil {
+		return hop, err
+	}
+	defer icmpConn.Close()
+
+	start := time.Now()
+	if err := icmpConn.SetReadDeadline(start.Add(timeout)); err != nil {
+		return hop, err
+	}
+
+	// The socket is bound before it connects, so the ICMP errors can be
+	// matched on the source port like those of UDP probes
+	bound := make(chan int, 1)
+	dialed := make(chan error, 1)
+	d := net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, c syscall.RawConn) error {
+			if err := bindDevice(c, device); err != nil {
+				return err
+			}
+			if err := setTTL(c, isV6, ttl); err != nil {
+				return err
+			}
+			localPort, err := bindLocal(c, isV6, source)
+			if err != nil {
+				return err
+			}
+			bound <- localPort
+			return nil
+		},
+	}
+	network := "tcp4"
+	if isV6 {
+		network = "tcp6"
+	}
+	go func() {
+		conn, err := d.Dial(network, net.JoinHostPort(target, strconv.Itoa(port)))
+		if conn != nil {
+			conn.Close()
+		}
+		close(bound)
+		dialed <- err
+	}()
+
+	replies := make(chan icmpReply, 1)
+	go func() {
+		localPort, ok := <-bound
+		if !ok {
+			close(replies)
+			return
+		}
+		reply, ok := readICMP(icmpConn, isV6, func(msg *icmp.Message) bool {
+			return quotesProbe(quotedData(msg), isV6, dst, protoTCP, localPort, port)
+		})
+		if ok {
+			replies <- reply
+		}
+		close(replies)
+	}()
+
+	// A failed connection may still have been answered by a router, so
+	// only the ICMP reader ends the wait then
+	dialing, waiting := dialed, replies
+	for dialing != nil || waiting != nil {
+		select {
+		case err := <-dialing:
+			if err == nil || errors.Is(err, syscall.ECONNREFUSED) {
+				hop.Success = true
+				hop.Addr = target
+				hop.Elapsed = time.Since(start)
+				return hop, nil
+			}
+			dialing = nil
+		case reply, ok := <-waiting:
+			if ok {
+				hop.Success = true
+				hop.Addr = reply.addr
+				hop.Elapsed = reply.at.Sub(start)
+				return hop, nil
+			}
+			waiting = nil
+		}
+	}
+	return hop, nil
+}
@@ -0,0 +1,101 @@
+package traceroute
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+)
+
+// quoted builds the start of a probe as quoted by an ICMP error: its IP
+// header with options bytes of options, then the source and destination
+// port
+func quoted(isV6 bool, options int, proto int, dst string, src, dstPort int) []byte {
+	var b []byte
+	if isV6 {
+		b = make([]byte, 40)
+		b[0] = 0x60
+		b[6] = byte(proto)
+		copy(b[24:40], net.ParseIP(dst).To16())
+	} else {
+		b = make([]byte, 20+options)
+		b[0] = 0x40 | byte((20+options)/4)
+		b[9] = byte(proto)
+		copy(b[16:20], net.ParseIP(dst).To4())
+	}
+	return binary.BigEndian.AppendUint16(binary.BigEndian.AppendUint16(b, uint16(src)), uint16(dstPort))
+}
+
+func TestQuotedPorts(t *testing.T) {
+	cases := []struct {
+		name    string
+		data    []byte
+		isV6    bool
+		dst     string
+		proto   int
+		src     int
+		dstPort int
+		ok      bool
+	}{
+		{"ipv4 udp", quoted(false, 0, protoUDP, "192.0.2.1", 40000, 33434), false, "192.0.2.1", protoUDP, 40000, 33434, true},
+		{"ipv4 options", quoted(false, 8, protoTCP, "192.0.2.1", 40001, 443), false, "192.0.2.1", protoTCP, 40001, 443, true},
+		{"ipv6 tcp", quoted(true, 0, protoTCP, "2001:db8::1", 40002, 80), true, "2001:db8::1", protoTCP, 40002, 80, true},
+		{"other destination", quoted(false, 0, protoUDP, "192.0.2.2", 40000, 33434), false, "192.0.2.1", 0, 0, 0, false},
+		{"ports cut off", quoted(false, 0, protoUDP, "192.0.2.1", 40000, 33434)[:22], false, "192.0.2.1", 0, 0, 0, false},
+		{"short ipv4 header", make([]byte, 12), false, "192.0.2.1", 0, 0, 0, false},
+		{"short ipv6 header", make([]byte, 30), true, "2001:db8::1", 0, 0, 0, false},
+	}
+	for _, c := range cases {
+		proto, src, dstPort, ok := quotedPorts(c.data, c.isV6, net.ParseIP(c.dst))
+		if ok != c.ok || proto != c.proto || src != c.src || dstPort != c.dstPort {
+			t.Errorf("%s: quotedPorts() = %d, %d, %d, %v", c.name, proto, src, dstPort, ok)
+		}
+	}
+}
+
+func TestQuotesProbe(t *testing.T) {
+	dst := net.ParseIP("192.0.2.1")
+	data := quoted(false, 0, protoTCP, "192.0.2.1", 40000, 443)
+
+	cases := []struct {
+		name      string
+		proto     int
+		localPort int
+		port      int
+		want      bool
+	}{
+		{"own probe", protoTCP, 40000, 443, true},
+		{"probe of another trace", protoTCP, 40001, 443, false},
+		{"other destination port", protoTCP, 40000, 80, false},
+		{"udp probe", protoUDP, 40000, 443, false},
+	}
+	for _, c := range cases {
+		if got := quotesProbe(data, false, dst, c.proto, c.localPort, c.port); got != c.want {
+			t.Errorf("%s: quotesProbe() = %v", c.name, got)
+		}
+	}
+}
+
+func TestQuotesEcho(t *testing.T) {
+	dst := net.ParseIP("192.0.2.1")
+	// Type, code and checksum, then the ID and sequence number
+	echo := func(proto int, id, seq int) []byte {
+		return binary.BigEndian.AppendUint16(binary.BigEndian.AppendUint16(quoted(false, 0, proto, "192.0.2.1", 0x0800, 0), uint16(id)), uint16(seq))
+	}
+
+	cases := []struct {
+		name string
+		data []byte
+		want bool
+	}{
+		{"own probe", echo(protoICMP, 1234, 7), true},
+		{"probe of another job", echo(protoICMP, 1235, 7), false},
+		{"earlier probe", echo(protoICMP, 1234, 6), false},
+		{"udp probe", echo(protoUDP, 1234, 7), false},
+		{"truncated", quoted(false, 0, protoICMP, "192.0.2.1", 0x0800, 0), false},
+	}
+	for _, c := range cases {
+		if got := quotesEcho(c.data, false, dst, 1234, 7); got != c.want {
+			t.Errorf("%s: quotesEcho() = %v", c.name, got)
+		}
+	}
+}
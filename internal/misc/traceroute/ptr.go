@@ -0,0 +1,84 @@
+package traceroute
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/LaunchPad-Network/NetPeek/constant"
+	"github.com/patrickmn/go-cache"
+	"github.com/spf13/viper"
+)
+
+// ptrCache holds reverse lookups of all requests. Failed lookups are
+// cached as empty names for a shorter time.
+var ptrCache = cache.New(constant.TraceroutePTRCacheDuration, 10*time.Minute)
+
+var resolverOnce sync.Once
+var resolver *net.Resolver
+
+// ptrResolver returns the resolver of reverse lookups. It queries
+// traceroute.resolver if set, e.g. the PoP's local resolver, or the
+// system resolver otherwise.
+func ptrResolver() *net.Resolver {
+	resolverOnce.Do(func() {
+		addr := viper.GetString("traceroute.resolver")
+		if addr == "" {
+			resolver = net.DefaultResolver
+			return
+		}
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			addr = net.JoinHostPort(addr, "53")
+		}
+		resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, addr)
+			},
+		}
+	})
+	return resolver
+}
+
+// lookupAddr resolves an address to its names. Tests replace it with a
+// stub resolver.
+var lookupAddr = func(ctx context.Context, ip string) ([]string, error) {
+	return ptrResolver().LookupAddr(ctx, ip)
+}
+
+// lookupPTR returns the reverse DNS name of ip, or "" if there is none
+// or the lookup did not finish in time
+func lookupPTR(ip string) string {
+	if v, found := ptrCache.Get(ip); found {
+		return v.(string)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), constant.TraceroutePTRTimeout)
+	defer cancel()
+	names, err := lookupAddr(ctx, ip)
+	if err != nil || len(names) == 0 {
+		ptrCache.Set(ip, "", constant.TraceroutePTRFailedCacheDuration)
+		return ""
+	}
+
+	name := strings.TrimSuffix(names[0], ".")
+	ptrCache.Set(ip, name, cache.DefaultExpiration)
+	return name
+}
+
+// lookupPTRs resolves the names of several addresses in parallel and
+// returns them by address
+func lookupPTRs(ips []string) map[string]string {
+	names := make(map[string]string, len(ips))
+	var mu sync.Mutex
+	forEachUnique(ips, constant.TraceroutePTRWorkers, func(ip string) {
+		name := lookupPTR(ip)
+		mu.Lock()
+		names[ip] = name
+		mu.Unlock()
+	})
+	return names
+}
@@ -0,0 +1,171 @@
+package traceroute
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/proxyapi"
+	"github.com/syepes/network_exporter/pkg/common"
+)
+
+// stubResolver replaces lookupAddr with names, counting the lookups of
+// every address. Addresses without a name fail to resolve.
+func stubResolver(t *testing.T, names map[string]string, delay map[string]time.Duration) map[string]int {
+	var mu sync.Mutex
+	calls := make(map[string]int)
+	orig := lookupAddr
+	lookupAddr = func(ctx context.Context, ip string) ([]string, error) {
+		mu.Lock()
+		calls[ip]++
+		mu.Unlock()
+		time.Sleep(delay[ip])
+		if name, ok := names[ip]; ok {
+			return []string{name}, nil
+		}
+		return nil, errors.New("no such host")
+	}
+	t.Cleanup(func() {
+		lookupAddr = orig
+		ptrCache.Flush()
+	})
+	ptrCache.Flush()
+	return calls
+}
+
+func TestLookupPTRCache(t *testing.T) {
+	calls := stubResolver(t, map[string]string{"192.0.2.1": "a.example.net."}, nil)
+
+	cases := []struct {
+		name  string
+		ip    string
+		want  string
+		calls int
+	}{
+		{"lookup", "192.0.2.1", "a.example.net", 1},
+		{"hit", "192.0.2.1", "a.example.net", 1},
+		{"failed lookup", "192.0.2.2", "", 1},
+		{"negative hit", "192.0.2.2", "", 1},
+	}
+	for _, c := range cases {
+		if got := lookupPTR(c.ip); got != c.want || calls[c.ip] != c.calls {
+			t.Errorf("%s: lookupPTR(%s) = %q after %d lookups, want %q after %d", c.name, c.ip, got, calls[c.ip], c.want, c.calls)
+		}
+	}
+
+	// Failed lookups are retried sooner than names are
+	_, nameExpiry, _ := ptrCache.GetWithExpiration("192.0.2.1")
+	_, failedExpiry, _ := ptrCache.GetWithExpiration("192.0.2.2")
+	if !failedExpiry.Before(nameExpiry) {
+		t.Errorf("failed lookup expires at %v, name at %v", failedExpiry, nameExpiry)
+	}
+
+	// Expired names are looked up again
+	ptrCache.Set("192.0.2.1", "old.example.net", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if got := lookupPTR("192.0.2.1"); got != "a.example.net" || calls["192.0.2.1"] != 2 {
+		t.Errorf("expired: lookupPTR() = %q after %d lookups", got, calls["192.0.2.1"])
+	}
+}
+
+func TestLookupPTRs(t *testing.T) {
+	calls := stubResolver(t, map[string]string{
+		"192.0.2.1": "a.example.net.",
+		"192.0.2.2": "b.example.net.",
+	}, nil)
+
+	cases := []struct {
+		ips  []string
+		want map[string]string
+	}{
+		{nil, map[string]string{}},
+		{[]string{"192.0.2.1", "192.0.2.1"}, map[string]string{"192.0.2.1": "a.example.net"}},
+		{[]string{"192.0.2.2", "192.0.2.3", "192.0.2.2"}, map[string]string{"192.0.2.2": "b.example.net", "192.0.2.3": ""}},
+		// Cached, including the failed lookup
+		{[]string{"192.0.2.1", "192.0.2.3"}, map[string]string{"192.0.2.1": "a.example.net", "192.0.2.3": ""}},
+	}
+	for _, c := range cases {
+		got := lookupPTRs(c.ips)
+		if len(got) != len(c.want) {
+			t.Errorf("lookupPTRs(%v) = %v, want %v", c.ips, got, c.want)
+			continue
+		}
+		for ip, name := range c.want {
+			if got[ip] != name {
+				t.Errorf("lookupPTRs(%v)[%s] = %q, want %q", c.ips, ip, got[ip], name)
+			}
+		}
+	}
+
+	for ip, n := range calls {
+		if n != 1 {
+			t.Errorf("%s looked up %d times", ip, n)
+		}
+	}
+}
+
+func TestHopWriterOrder(t *testing.T) {
+	// Earlier hops resolve slower than later ones
+	stubResolver(t, map[string]string{
+		"10.0.0.1": "one.example.net",
+		"10.0.0.2": "two.example.net",
+		"10.0.0.3": "three.example.net",
+	}, map[string]time.Duration{
+		"10.0.0.1": 30 * time.Millisecond,
+		"10.0.0.2": 10 * time.Millisecond,
+	})
+
+	var got []proxyapi.TracerouteHop
+	hw := newHopWriter(4, func(hop proxyapi.TracerouteHop) error {
+		got = append(got, hop)
+		return nil
+	})
+	for ttl, addr := range []string{"10.0.0.1", "10.0.0.2", "", "10.0.0.3"} {
+		hop := common.IcmpHop{TTL: ttl + 1, AddressTo: addr, Success: addr != ""}
+		if err := hw.Add(hop); err != nil {
+			t.Fatalf("Add() = %v", err)
+		}
+	}
+	if err := hw.Close(); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+
+	want := []struct {
+		hop int
+		ptr string
+	}{
+		{1, "one.example.net"},
+		{2, "two.example.net"},
+		{3, ""},
+		{4, "three.example.net"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("wrote %d hops, want %d", len(got), len(want))
+	}
+	for i, w := range want {
+		if got[i].Hop != w.hop || got[i].PTR != w.ptr {
+			t.Errorf("hop %d = %d %q, want %d %q", i, got[i].Hop, got[i].PTR, w.hop, w.ptr)
+		}
+	}
+}
+
+func TestHopWriterError(t *testing.T) {
+	stubResolver(t, nil, nil)
+
+	errWrite := errors.New("connection closed")
+	writes := 0
+	hw := newHopWriter(3, func(hop proxyapi.TracerouteHop) error {
+		writes++
+		return errWrite
+	})
+	hw.Add(common.IcmpHop{TTL: 1})
+	hw.Add(common.IcmpHop{TTL: 2})
+	if err := hw.Close(); err != errWrite {
+		t.Errorf("Close() = %v, want %v", err, errWrite)
+	}
+	if writes != 1 {
+		t.Errorf("wrote %d times, want 1", writes)
+	}
+}
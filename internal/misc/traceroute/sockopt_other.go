@@ -0,0 +1,15 @@
+//go:build !unix
+
+package traceroute
+
+import "syscall"
+
+// setTTL is not available here, so TCP probes are not supported
+func setTTL(c syscall.RawConn, isV6 bool, ttl int) error {
+	return ErrNotSupported
+}
+
+// bindLocal is not available here, so TCP probes are not supported
+func bindLocal(c syscall.RawConn, isV6 bool, source string) (int, error) {
+	return 0, ErrNotSupported
+}
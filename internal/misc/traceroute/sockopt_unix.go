@@ -0,0 +1,67 @@
+//go:build unix
+
+package traceroute
+
+import (
+	"net"
+	"syscall"
+)
+
+// setTTL sets the TTL or hop limit of the packets a socket sends
+func setTTL(c syscall.RawConn, isV6 bool, ttl int) error {
+	var sockErr error
+	err := c.Control(func(fd uintptr) {
+		if isV6 {
+			sockErr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6, syscall.IPV6_UNICAST_HOPS, ttl)
+		} else {
+			sockErr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_TTL, ttl)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return sockErr
+}
+
+// bindLocal binds a socket to an ephemeral port of source, or of any
+// address if it is empty, and returns the port. It is called before the
+// socket connects, so replies to the very first packet can be matched.
+func bindLocal(c syscall.RawConn, isV6 bool, source string) (int, error) {
+	var sa syscall.Sockaddr
+	ip := net.ParseIP(source)
+	if isV6 {
+		sa6 := &syscall.SockaddrInet6{}
+		if ip != nil {
+			copy(sa6.Addr[:], ip.To16())
+		}
+		sa = sa6
+	} else {
+		sa4 := &syscall.SockaddrInet4{}
+		if ip != nil {
+			copy(sa4.Addr[:], ip.To4())
+		}
+		sa = sa4
+	}
+
+	var port int
+	var sockErr error
+	err := c.Control(func(fd uintptr) {
+		if sockErr = syscall.Bind(int(fd), sa); sockErr != nil {
+			return
+		}
+		var local syscall.Sockaddr
+		if local, sockErr = syscall.Getsockname(int(fd)); sockErr != nil {
+			return
+		}
+		switch local := local.(type) {
+		case *syscall.SockaddrInet4:
+			port = local.Port
+		case *syscall.SockaddrInet6:
+			port = local.Port
+		}
+	})
+	if err != nil {
+		return 0, err
+	}
+	return port, sockErr
+}
@@ -0,0 +1,214 @@
+package traceroute
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"time"
+
+	"github.com/LaunchPad-Network/NetPeek/constant"
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/proxyapi"
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/validator"
+	"github.com/patrickmn/go-cache"
+	"github.com/spf13/viper"
+)
+
+// Source is a named profile traceroutes and pings can send probes from,
+// e.g. an anycast loopback or the address of one uplink. Probes of a
+// profile with an Interface are bound to it, and the address of a family
+// that is not configured is taken from it.
+type Source struct {
+	Name      string `mapstructure:"name"`
+	IPv4      string `mapstructure:"ipv4"`
+	IPv6      string `mapstructure:"ipv6"`
+	Interface string `mapstructure:"interface"`
+}
+
+type sourcesConfig struct {
+	Traceroute struct {
+		Sources []Source `mapstructure:"sources"`
+	} `mapstructure:"traceroute"`
+}
+
+var sources []Source
+
+// LoadSources loads and checks the profiles in traceroute.sources.
+// Configured addresses must be assigned to the interface of their profile.
+func LoadSources() error {
+	var cfg sourcesConfig
+	if err := viper.Unmarshal(&cfg); err != nil {
+		return fmt.Errorf("invalid traceroute.sources: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	for _, src := range cfg.Traceroute.Sources {
+		if !validator.IsValidSourceName(src.Name) {
+			return fmt.Errorf("invalid traceroute source name %q", src.Name)
+		}
+		if seen[src.Name] {
+			return fmt.Errorf("duplicate traceroute source %q", src.Name)
+		}
+		seen[src.Name] = true
+
+		if isV4, _ := validator.IsIP(src.IPv4); src.IPv4 != "" && !isV4 {
+			return fmt.Errorf("traceroute source %q: invalid ipv4 %q", src.Name, src.IPv4)
+		}
+		if _, isV6 := validator.IsIP(src.IPv6); src.IPv6 != "" && !isV6 {
+			return fmt.Errorf("traceroute source %q: invalid ipv6 %q", src.Name, src.IPv6)
+		}
+		if src.Interface == "" {
+			if src.IPv4 == "" && src.IPv6 == "" {
+				return fmt.Errorf("traceroute source %q has neither an address nor an interface", src.Name)
+			}
+			continue
+		}
+
+		addrs, err := listInterfaceAddrs(src.Interface)
+		if err != nil {
+			return fmt.Errorf("traceroute source %q: %w", src.Name, err)
+		}
+		for _, addr := range []string{src.IPv4, src.IPv6} {
+			if addr != "" && !containsAddr(addrs, netip.MustParseAddr(addr)) {
+				return fmt.Errorf("traceroute source %q: %s is not assigned to %s", src.Name, addr, src.Interface)
+			}
+		}
+	}
+
+	sources = cfg.Traceroute.Sources
+	return nil
+}
+
+// Sources returns the configured source profiles
+func Sources() []Source {
+	return sources
+}
+
+// Address returns the source address of the given family, or "" if the
+// profile has none
+func (s Source) Address(isV6 bool) string {
+	addr := s.IPv4
+	if isV6 {
+		addr = s.IPv6
+	}
+	if addr != "" || s.Interface == "" {
+		return addr
+	}
+
+	// Link-local addresses would need a zone, so only routable ones are
+	// picked from the interface
+	addrs, err := interfaceAddrs(s.Interface)
+	if err != nil {
+		return ""
+	}
+	for _, a := range addrs {
+		if a.Is6() == isV6 && !a.IsLinkLocalUnicast() && !a.IsLoopback() {
+			return a.String()
+		}
+	}
+	return ""
+}
+
+// family returns the address family of the profile if it only has
+// addresses of one
+func (s Source) family() string {
+	v4, v6 := s.Address(false) != "", s.Address(true) != ""
+	switch {
+	case v4 && !v6:
+		return proxyapi.FamilyIPv4
+	case v6 && !v4:
+		return proxyapi.FamilyIPv6
+	default:
+		return proxyapi.FamilyAny
+	}
+}
+
+// lookupSource finds the profile a request asks for, nil for none
+func lookupSource(name string) (*Source, error) {
+	if name == "" {
+		return nil, nil
+	}
+	for i := range sources {
+		if sources[i].Name == name {
+			return &sources[i], nil
+		}
+	}
+	return nil, ErrUnknownSource
+}
+
+// resolveSource resolves the target of a request and the address and
+// interface of its source profile. Domain names are resolved to the family
+// of the profile if it has only one.
+func resolveSource(ctx context.Context, args proxyapi.Args) (target string, isV6 bool, source, device string, err error) {
+	src, err := lookupSource(args.Source)
+	if err != nil {
+		return "", false, "", "", err
+	}
+
+	family := args.Family
+	if src != nil && family == proxyapi.FamilyAny && validator.IsDomain(args.Target) {
+		family = src.family()
+	}
+	target, isV6, err = resolveTarget(ctx, args.Target, family)
+	if err != nil || src == nil {
+		return target, isV6, "", "", err
+	}
+
+	source = src.Address(isV6)
+	if source == "" {
+		return "", false, "", "", ErrNoSourceAddress
+	}
+	return target, isV6, source, src.Interface, nil
+}
+
+// ifaceCache holds the addresses of source interfaces for a short time,
+// so they are not enumerated for every request
+var ifaceCache = cache.New(constant.TracerouteSourceCacheDuration, time.Minute)
+
+// interfaceAddrs returns the addresses assigned to the named interface,
+// as they were up to TracerouteSourceCacheDuration ago
+func interfaceAddrs(name string) ([]netip.Addr, error) {
+	if v, found := ifaceCache.Get(name); found {
+		return v.([]netip.Addr), nil
+	}
+	addrs, err := listInterfaceAddrs(name)
+	if err != nil {
+		return nil, err
+	}
+	ifaceCache.Set(name, addrs, cache.DefaultExpiration)
+	return addrs, nil
+}
+
+// listInterfaceAddrs returns the addresses assigned to the named
+// interface. Tests replace it with stub interfaces.
+var listInterfaceAddrs = func(name string) ([]netip.Addr, error) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, err
+	}
+	ifAddrs, err := iface.Addrs()
+	if err != nil {
+		return nil, err
+	}
+
+	var addrs []netip.Addr
+	for _, a := range ifAddrs {
+		ipNet, ok := a.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		if addr, ok := netip.AddrFromSlice(ipNet.IP); ok {
+			addrs = append(addrs, addr.Unmap())
+		}
+	}
+	return addrs, nil
+}
+
+func containsAddr(addrs []netip.Addr, addr netip.Addr) bool {
+	for _, a := range addrs {
+		if a == addr {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,133 @@
+package traceroute
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"testing"
+
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/proxyapi"
+	"github.com/spf13/viper"
+)
+
+// stubInterfaces replaces the interfaces of the host with ifaces
+func stubInterfaces(t *testing.T, ifaces map[string][]string) {
+	orig := listInterfaceAddrs
+	listInterfaceAddrs = func(name string) ([]netip.Addr, error) {
+		addrs, ok := ifaces[name]
+		if !ok {
+			return nil, errors.New("no such network interface")
+		}
+		var res []netip.Addr
+		for _, a := range addrs {
+			res = append(res, netip.MustParseAddr(a))
+		}
+		return res, nil
+	}
+	ifaceCache.Flush()
+	t.Cleanup(func() {
+		listInterfaceAddrs = orig
+		ifaceCache.Flush()
+	})
+}
+
+var testInterfaces = map[string][]string{
+	"eth1": {"fe80::1", "198.51.100.5", "2001:db8::5"},
+	"eth2": {"127.0.0.1", "192.0.2.9"},
+}
+
+func TestLoadSources(t *testing.T) {
+	stubInterfaces(t, testInterfaces)
+	defer viper.Set("traceroute.sources", nil)
+	defer func() { sources = nil }()
+
+	cases := []struct {
+		name    string
+		sources []map[string]any
+		ok      bool
+	}{
+		{"valid", []map[string]any{
+			{"name": "anycast", "ipv4": "192.0.2.1", "ipv6": "2001:db8::1"},
+			{"name": "transit1", "interface": "eth1", "ipv6": "2001:db8::5"},
+		}, true},
+		{"invalid name", []map[string]any{{"name": "-anycast", "ipv4": "192.0.2.1"}}, false},
+		{"no name", []map[string]any{{"ipv4": "192.0.2.1"}}, false},
+		{"duplicate", []map[string]any{
+			{"name": "anycast", "ipv4": "192.0.2.1"},
+			{"name": "anycast", "ipv4": "192.0.2.2"},
+		}, false},
+		{"ipv6 as ipv4", []map[string]any{{"name": "anycast", "ipv4": "2001:db8::1"}}, false},
+		{"ipv4 as ipv6", []map[string]any{{"name": "anycast", "ipv6": "192.0.2.1"}}, false},
+		{"nothing", []map[string]any{{"name": "anycast"}}, false},
+		{"unknown interface", []map[string]any{{"name": "transit1", "interface": "eth9"}}, false},
+		{"address not on interface", []map[string]any{{"name": "transit1", "interface": "eth1", "ipv4": "192.0.2.9"}}, false},
+	}
+	for _, c := range cases {
+		viper.Set("traceroute.sources", c.sources)
+		err := LoadSources()
+		if (err == nil) != c.ok {
+			t.Errorf("%s: LoadSources() = %v", c.name, err)
+		}
+		if err == nil && len(Sources()) != len(c.sources) {
+			t.Errorf("%s: loaded %d sources", c.name, len(Sources()))
+		}
+	}
+}
+
+func TestSourceAddress(t *testing.T) {
+	stubInterfaces(t, testInterfaces)
+
+	cases := []struct {
+		src    Source
+		ipv4   string
+		ipv6   string
+		family string
+	}{
+		{Source{IPv4: "192.0.2.1", IPv6: "2001:db8::1"}, "192.0.2.1", "2001:db8::1", proxyapi.FamilyAny},
+		{Source{IPv4: "192.0.2.1"}, "192.0.2.1", "", proxyapi.FamilyIPv4},
+		{Source{Interface: "eth1"}, "198.51.100.5", "2001:db8::5", proxyapi.FamilyAny},
+		{Source{Interface: "eth1", IPv6: "2001:db8::6"}, "198.51.100.5", "2001:db8::6", proxyapi.FamilyAny},
+		{Source{Interface: "eth2"}, "192.0.2.9", "", proxyapi.FamilyIPv4},
+		{Source{Interface: "eth9"}, "", "", proxyapi.FamilyAny},
+	}
+	for _, c := range cases {
+		if got := c.src.Address(false); got != c.ipv4 {
+			t.Errorf("%+v: Address(false) = %q, want %q", c.src, got, c.ipv4)
+		}
+		if got := c.src.Address(true); got != c.ipv6 {
+			t.Errorf("%+v: Address(true) = %q, want %q", c.src, got, c.ipv6)
+		}
+		if got := c.src.family(); got != c.family {
+			t.Errorf("%+v: family() = %q, want %q", c.src, got, c.family)
+		}
+	}
+}
+
+func TestResolveSource(t *testing.T) {
+	stubInterfaces(t, testInterfaces)
+	sources = []Source{
+		{Name: "anycast", IPv4: "192.0.2.1", IPv6: "2001:db8::1"},
+		{Name: "transit2", Interface: "eth2"},
+	}
+	defer func() { sources = nil }()
+
+	cases := []struct {
+		args   proxyapi.Args
+		target string
+		source string
+		err    error
+	}{
+		{proxyapi.Args{Target: "203.0.113.1"}, "203.0.113.1", "", nil},
+		{proxyapi.Args{Target: "203.0.113.1", Source: "anycast"}, "203.0.113.1", "192.0.2.1", nil},
+		{proxyapi.Args{Target: "2001:db8:1::1", Source: "anycast"}, "2001:db8:1::1", "2001:db8::1", nil},
+		{proxyapi.Args{Target: "203.0.113.1", Source: "transit2"}, "203.0.113.1", "192.0.2.9", nil},
+		{proxyapi.Args{Target: "2001:db8:1::1", Source: "transit2"}, "", "", ErrNoSourceAddress},
+		{proxyapi.Args{Target: "203.0.113.1", Source: "transit9"}, "", "", ErrUnknownSource},
+	}
+	for _, c := range cases {
+		target, _, source, _, err := resolveSource(context.Background(), c.args)
+		if target != c.target || source != c.source || !errors.Is(err, c.err) {
+			t.Errorf("resolveSource(%+v) = %q, %q, %v", c.args, target, source, err)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package traceroute
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/proxyapi"
+	"github.com/syepes/network_exporter/pkg/common"
+	"github.com/syepes/network_exporter/pkg/mtr"
+)
+
+// Options are the probing settings of a traceroute
+type Options struct {
+	MaxHops int
+	Count   int
+	Timeout time.Duration
+	Size    int
+	// Protocol of the probes, see Protocols. Port is the destination
+	// port of TCP and UDP probes.
+	Protocol string
+	Port     int
+	// Source is the name of the source profile, SourceAddress the
+	// address probes are sent from and SourceInterface the interface they
+	// leave through. All are empty to let the kernel pick the source.
+	Source          string
+	SourceAddress   string
+	SourceInterface string
+}
+
+// send sends a single probe with the given TTL
+func (opts Options) send(target string, ipv6 bool, ttl, pid, seq int) (common.IcmpReturn, error) {
+	switch opts.Protocol {
+	case proxyapi.ProbeTCP:
+		return probeTCP(target, opts.SourceAddress, opts.SourceInterface, ipv6, ttl, opts.Port, opts.Timeout)
+	case proxyapi.ProbeUDP:
+		return probeUDP(target, opts.SourceAddress, opts.SourceInterface, ipv6, ttl, opts.Port, opts.Timeout, opts.Size)
+	default:
+		return probeICMP(target, opts.SourceAddress, opts.SourceInterface, ipv6, ttl, pid, seq, opts.Timeout, opts.Size)
+	}
+}
+
+// probeHop sends count probes with the given TTL and summarizes the replies
+// the same way mtr.Mtr does, except that Loss is in percent. It stops
+// sending probes once ctx is done.
+func probeHop(ctx context.Context, target string, ipv6 bool, ttl, pid int, seq *int, opts Options) common.IcmpHop {
+	hop := common.IcmpHop{TTL: ttl, Snt: opts.Count, AddressTo: "unknown"}
+	var times []time.Duration
+
+	for i := 0; i < opts.Count && ctx.Err() == nil; i++ {
+		ret, err := opts.send(target, ipv6, ttl, pid, *seq)
+		*seq++
+		if err != nil || !ret.Success {
+			continue
+		}
+
+		hop.Success = true
+		hop.AddressTo = ret.Addr
+		hop.LastTime = ret.Elapsed
+		hop.SumTime += ret.Elapsed
+		if hop.BestTime == 0 || ret.Elapsed < hop.BestTime {
+			hop.BestTime = ret.Elapsed
+		}
+		if ret.Elapsed > hop.WorstTime {
+			hop.WorstTime = ret.Elapsed
+		}
+		times = append(times, ret.Elapsed)
+	}
+
+	if len(times) > 0 {
+		hop.AvgTime = hop.SumTime / time.Duration(len(times))
+		hop.SquaredDeviationTime = time.Duration(math.Sqrt(common.TimeSquaredDeviation(times)))
+		hop.UncorrectedSDTime = time.Duration(common.TimeUncorrectedDeviation(times))
+		hop.CorrectedSDTime = time.Duration(common.TimeCorrectedDeviation(times))
+		hop.RangeTime = common.TimeRange(times)
+	}
+	hop.SntFail = opts.Count - len(times)
+	hop.Loss = float64(hop.SntFail) / float64(opts.Count) * 100
+	return hop
+}
+
+// trace probes the target one TTL at a time and calls onHop as soon as a
+// hop is complete, so the output can be streamed while later hops are
+// still being measured. pid is the ICMP echo ID of the probes. It stops
+// with the error of ctx once ctx is done, e.g. when the client went away.
+func trace(ctx context.Context, target string, ipv6 bool, opts Options, pid int, onHop func(common.IcmpHop) error) (*mtr.MtrResult, error) {
+	out := &mtr.MtrResult{DestAddr: target}
+	seq := 0
+	from := ""
+
+	for ttl := 1; ttl <= opts.MaxHops; ttl++ {
+		hop := probeHop(ctx, target, ipv6, ttl, pid, &seq, opts)
+		if err := ctx.Err(); err != nil {
+			return out, err
+		}
+		hop.AddressFrom = from
+		if ttl == 1 {
+			hop.AddressFrom = hop.AddressTo
+		}
+		from = hop.AddressTo
+
+		out.Hops = append(out.Hops, hop)
+		if onHop != nil {
+			if err := onHop(hop); err != nil {
+				return out, err
+			}
+		}
+
+		if common.IsEqualIP(hop.AddressTo, target) {
+			break
+		}
+	}
+	return out, nil
+}
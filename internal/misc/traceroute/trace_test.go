@@ -0,0 +1,33 @@
+package traceroute
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/proxyapi"
+	"github.com/syepes/network_exporter/pkg/common"
+)
+
+func TestTraceCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	opts := Options{Protocol: proxyapi.ProbeICMP, MaxHops: 30, Count: 3, Timeout: time.Second, Size: 56}
+	calls := 0
+	out, err := trace(ctx, "192.0.2.1", false, opts, 1, func(common.IcmpHop) error {
+		calls++
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("trace() = %v, want %v", err, context.Canceled)
+	}
+	if len(out.Hops) != 0 || calls != 0 {
+		t.Errorf("trace() measured %d hops and passed on %d", len(out.Hops), calls)
+	}
+
+	if _, _, err := resolveTarget(ctx, "example.com", proxyapi.FamilyAny); err == nil {
+		t.Error("resolveTarget() resolved with a canceled context")
+	}
+}
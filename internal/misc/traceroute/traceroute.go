@@ -1,73 +1,238 @@
 package traceroute
 
 import (
+	"context"
+	"encoding/json"
+	"io"
 	"net"
 	"strings"
 	"time"
 
+	"github.com/LaunchPad-Network/NetPeek/constant"
+	"github.com/LaunchPad-Network/NetPeek/internal/logger"
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/proxyapi"
 	"github.com/LaunchPad-Network/NetPeek/internal/misc/validator"
 	"github.com/lfcypo/viperx"
 	"github.com/spf13/viper"
-	"github.com/syepes/network_exporter/pkg/common"
-	"github.com/syepes/network_exporter/pkg/mtr"
 )
 
-var icmpID = common.IcmpID{}
-
-func callMTR(q string) (*mtr.MtrResult, error) {
-	if viper.GetBool("traceroute.disable") {
-		return nil, ErrNotSupported
-	}
+var log = logger.New("Traceroute")
 
+// resolveTarget validates q and resolves domain names to an address of
+// the given family, "ipv4", "ipv6" or "" for either
+func resolveTarget(ctx context.Context, q, family string) (string, bool, error) {
 	q = strings.TrimSpace(q)
 	if q == "" {
-		return nil, ErrEmptyTarget
+		return "", false, ErrEmptyTarget
 	}
 
 	isV4, isV6 := validator.IsIP(q)
 	isDomain := validator.IsDomain(q)
 	if !isV4 && !isV6 && !isDomain {
-		return nil, ErrInvalidTarget
+		return "", false, ErrInvalidTarget
+	}
+	if (family == "ipv4" && isV6) || (family == "ipv6" && isV4) {
+		return "", false, ErrInvalidTarget
 	}
 	target := q
 
 	if isDomain {
-		names, err := net.LookupHost(target)
+		network := "ip"
+		switch family {
+		case "ipv4":
+			network = "ip4"
+		case "ipv6":
+			network = "ip6"
+		}
+		ctx, cancel := context.WithTimeout(ctx, constant.TracerouteResolveTimeout)
+		defer cancel()
+		ips, err := net.DefaultResolver.LookupIP(ctx, network, target)
 		if err != nil {
-			return nil, err
+			return "", false, err
 		}
-		target = names[0]
+		target = ips[0].String()
 		_, isV6 = validator.IsIP(target)
 	}
 
-	return mtr.Mtr(
-		target,
-		"",
-		viperx.GetInt("traceroute.maxhops", 30),
-		viperx.GetInt("traceroute.count", 3),
-		time.Duration(viperx.GetInt("traceroute.timeout", 1))*time.Second,
-		int(icmpID.Get()),
-		viperx.GetInt("traceroute.size", 56),
-		"icmp",
-		"80",
-		isV6,
-	)
+	return target, isV6, nil
+}
+
+// Enabled reports whether traceroute is available on this proxy
+func Enabled() bool {
+	return !viper.GetBool("traceroute.disable")
+}
+
+// LoadOptions returns the configured probing settings. MaxHops and Count
+// are the defaults of requests, MaxHops is also the most hops a request
+// may ask for.
+func LoadOptions() Options {
+	return Options{
+		MaxHops:  viperx.GetInt("traceroute.maxhops", 30),
+		Count:    viperx.GetInt("traceroute.count", 3),
+		Timeout:  time.Duration(viperx.GetInt("traceroute.timeout", 1)) * time.Second,
+		Size:     viperx.GetInt("traceroute.size", 56),
+		Protocol: proxyapi.ProbeICMP,
+	}
+}
+
+// MaxCount is the most probes per hop a request may ask for
+func MaxCount() int {
+	return viperx.GetInt("traceroute.max_count", 10)
+}
+
+// EnabledProtocols lists the probe protocols this proxy offers
+func EnabledProtocols() []string {
+	protocols := viper.GetStringSlice("traceroute.protocols")
+	if len(protocols) == 0 {
+		return Protocols
+	}
+	return protocols
+}
+
+func protocolEnabled(protocol string) bool {
+	for _, p := range EnabledProtocols() {
+		if p == protocol {
+			return true
+		}
+	}
+	return false
+}
+
+// options applies the arguments of a request to the configured settings.
+// Hops and probes per hop are capped by the configured bounds.
+func options(args proxyapi.Args) (Options, error) {
+	opts := LoadOptions()
+	if args.Probe != "" {
+		opts.Protocol = args.Probe
+	}
+	if !protocolEnabled(opts.Protocol) {
+		return opts, ErrNotSupported
+	}
+	if opts.Protocol != proxyapi.ProbeICMP {
+		opts.Port = args.Port
+		if opts.Port == 0 {
+			opts.Port = DefaultPort(opts.Protocol)
+		}
+	}
+	if args.MaxHops > 0 {
+		opts.MaxHops = min(args.MaxHops, opts.MaxHops)
+	}
+	if args.Count > 0 {
+		opts.Count = min(args.Count, MaxCount())
+	}
+	return opts, nil
 }
 
-func CallTraceroute(q string) (string, error) {
-	out, err := callMTR(q)
+// prepare resolves the target of a traceroute request and its settings
+func prepare(ctx context.Context, args proxyapi.Args) (string, bool, Options, error) {
+	if !Enabled() {
+		return "", false, Options{}, ErrNotSupported
+	}
+	opts, err := options(args)
 	if err != nil {
-		return "", err
+		return "", false, opts, err
+	}
+	target, isV6, source, device, err := resolveSource(ctx, args)
+	if err != nil {
+		return "", false, opts, err
+	}
+	opts.Source = args.Source
+	opts.SourceAddress = source
+	opts.SourceInterface = device
+	return target, isV6, opts, nil
+}
+
+// Traceroute runs a traceroute and returns the document describing it.
+// probeID is the ICMP echo ID of the probes, unique among running jobs.
+// It stops early if ctx is done.
+func Traceroute(ctx context.Context, args proxyapi.Args, probeID int) (*proxyapi.TracerouteResult, error) {
+	target, isV6, opts, err := prepare(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	out, err := trace(ctx, target, isV6, opts, probeID, nil)
+	if err != nil {
+		return nil, err
+	}
+	return buildResult(args.Target, target, opts, start, out), nil
+}
+
+// StreamTraceroute runs a traceroute and writes the text output to w hop
+// by hop. Errors about the request are returned before anything is
+// written.
+func StreamTraceroute(ctx context.Context, args proxyapi.Args, probeID int, w io.Writer) error {
+	target, isV6, opts, err := prepare(ctx, args)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(w, formatHeader(target, opts)); err != nil {
+		return err
+	}
+	hw := newHopWriter(opts.MaxHops, func(hop proxyapi.TracerouteHop) error {
+		_, err := io.WriteString(w, formatHop(hop))
+		return err
+	})
+	_, err = trace(ctx, target, isV6, opts, probeID, hw.Add)
+	if werr := hw.Close(); err == nil {
+		err = werr
+	}
+	return err
+}
+
+// StreamTracerouteJSON runs a traceroute and writes it to w as lines of
+// the FormatJSONLines format, hop by hop. Errors about the request are
+// returned before anything is written.
+func StreamTracerouteJSON(ctx context.Context, args proxyapi.Args, probeID int, w io.Writer) error {
+	target, isV6, opts, err := prepare(ctx, args)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(proxyapi.TracerouteEvent{Result: newResult(args.Target, target, opts, time.Now())}); err != nil {
+		return err
 	}
 
-	return formatString(out), nil
+	// Unanswered hops are held back until the next hop is measured, so
+	// the last one can be marked Final
+	var held *proxyapi.TracerouteHop
+	hw := newHopWriter(opts.MaxHops, func(hop proxyapi.TracerouteHop) error {
+		if held != nil {
+			if err := enc.Encode(proxyapi.TracerouteEvent{Hop: held}); err != nil {
+				return err
+			}
+			held = nil
+		}
+		if !hop.Success {
+			held = &hop
+			return nil
+		}
+		return enc.Encode(proxyapi.TracerouteEvent{Hop: &hop})
+	})
+	_, err = trace(ctx, target, isV6, opts, probeID, hw.Add)
+	if werr := hw.Close(); err == nil {
+		err = werr
+	}
+	if err != nil {
+		return err
+	}
+	if held != nil {
+		held.Final = true
+		if err := enc.Encode(proxyapi.TracerouteEvent{Hop: held}); err != nil {
+			return err
+		}
+	}
+	end := time.Now()
+	return enc.Encode(proxyapi.TracerouteEvent{End: &end})
 }
 
-func CallTracerouteHTML(q string) (string, error) {
-	out, err := callMTR(q)
+func CallTracerouteHTML(ctx context.Context, args proxyapi.Args, probeID int) (string, error) {
+	res, err := Traceroute(ctx, args, probeID)
 	if err != nil {
 		return "", err
 	}
 
-	return formatHTML(out)
+	return formatHTML(res)
 }
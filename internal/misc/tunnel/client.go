@@ -0,0 +1,101 @@
+package tunnel
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/LaunchPad-Network/NetPeek/constant"
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/crypto/hmac"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/websocket"
+)
+
+// DialOptions describe how a proxy connects to the frontend
+type DialOptions struct {
+	// URL of the frontend's tunnel endpoint, ws:// or wss://
+	URL    string
+	Node   string
+	Secret []byte
+	TLS    *tls.Config
+}
+
+// dial opens the WebSocket and authenticates as the PoP
+func dial(opts DialOptions) (*websocket.Conn, error) {
+	u, err := url.Parse(opts.URL)
+	if err != nil {
+		return nil, err
+	}
+	origin := &url.URL{Scheme: "http", Host: u.Host}
+	if u.Scheme == "wss" {
+		origin.Scheme = "https"
+	}
+
+	nonce, err := newNonce()
+	if err != nil {
+		return nil, err
+	}
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	sig, _ := (&hmac.Signer{Key: opts.Secret}).Sign(payload(opts.Node, ts, nonce))
+
+	cfg := &websocket.Config{
+		Location: u,
+		Origin:   origin,
+		Version:  websocket.ProtocolVersionHybi13,
+		Header: http.Header{
+			HeaderNode:      {opts.Node},
+			HeaderTs:        {ts},
+			HeaderNonce:     {nonce},
+			HeaderSignature: {sig},
+		},
+		Dialer: &net.Dialer{Timeout: constant.TunnelDialTimeout},
+	}
+	if opts.TLS != nil {
+		// The WebSocket upgrade needs HTTP/1.1
+		cfg.TlsConfig = opts.TLS.Clone()
+		cfg.TlsConfig.NextProtos = []string{"http/1.1"}
+	}
+
+	ws, err := websocket.DialConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	ws.PayloadType = websocket.BinaryFrame
+	return ws, nil
+}
+
+// Serve keeps a tunnel to the frontend open and serves h over it until
+// ctx is done, reconnecting with a growing delay after failures.
+func Serve(ctx context.Context, opts DialOptions, h http.Handler) {
+	delay := constant.TunnelRetryMinDelay
+	for ctx.Err() == nil {
+		ws, err := dial(opts)
+		if err != nil {
+			log.Errorf("Failed to connect to %s: %v, retrying in %s", opts.URL, err, delay)
+		} else {
+			log.Infof("Connected to %s", opts.URL)
+			start := time.Now()
+			(&http2.Server{}).ServeConn(ws, &http2.ServeConnOpts{
+				Context: ctx,
+				Handler: h,
+			})
+			ws.Close()
+			if time.Since(start) > constant.TunnelRetryMaxDelay {
+				delay = constant.TunnelRetryMinDelay
+			}
+			log.Warnf("Tunnel to %s closed, reconnecting in %s", opts.URL, delay)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, constant.TunnelRetryMaxDelay)
+	}
+}
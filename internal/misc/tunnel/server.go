@@ -0,0 +1,149 @@
+package tunnel
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/LaunchPad-Network/NetPeek/constant"
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/crypto/hmac"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/websocket"
+)
+
+// tunnel is an open connection of a proxy to the frontend
+type tunnel struct {
+	cc     *http2.ClientConn
+	client *http.Client
+}
+
+var tunnels = struct {
+	sync.RWMutex
+	byNode map[string]*tunnel
+}{byNode: make(map[string]*tunnel)}
+
+// Client returns a client sending requests through the tunnel of a PoP
+func Client(node string) (*http.Client, bool) {
+	tunnels.RLock()
+	defer tunnels.RUnlock()
+	t, ok := tunnels.byNode[strings.ToLower(node)]
+	if !ok {
+		return nil, false
+	}
+	return t.client, true
+}
+
+// Connected lists the PoPs with an open tunnel
+func Connected() []string {
+	tunnels.RLock()
+	defer tunnels.RUnlock()
+	nodes := make([]string, 0, len(tunnels.byNode))
+	for node := range tunnels.byNode {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+	return nodes
+}
+
+// IsConnected reports whether a PoP has an open tunnel
+func IsConnected(node string) bool {
+	_, ok := Client(node)
+	return ok
+}
+
+func register(node string, t *tunnel) {
+	tunnels.Lock()
+	old := tunnels.byNode[node]
+	tunnels.byNode[node] = t
+	tunnels.Unlock()
+
+	if old != nil {
+		log.Infof("PoP %s reconnected, closing its previous tunnel", node)
+		old.cc.Close()
+	}
+}
+
+func unregister(node string, t *tunnel) {
+	tunnels.Lock()
+	defer tunnels.Unlock()
+	if tunnels.byNode[node] == t {
+		delete(tunnels.byNode, node)
+	}
+}
+
+// Handler accepts tunnels from proxies. secretOf returns the hex encoded
+// tunnel secret of a PoP, or false if it may not connect.
+func Handler(secretOf func(node string) (string, bool)) http.Handler {
+	return websocket.Server{
+		Handshake: func(cfg *websocket.Config, r *http.Request) error {
+			return authenticate(r, secretOf)
+		},
+		Handler: serve,
+	}
+}
+
+func authenticate(r *http.Request, secretOf func(node string) (string, bool)) error {
+	node := strings.ToLower(r.Header.Get(HeaderNode))
+	secretHex, ok := secretOf(node)
+	if !ok {
+		return fmt.Errorf("PoP %q may not open a tunnel", node)
+	}
+	key, err := hmac.ImportKeyHex(secretHex)
+	if err != nil {
+		return fmt.Errorf("invalid tunnel secret of PoP %q: %w", node, err)
+	}
+
+	ts, nonce := r.Header.Get(HeaderTs), r.Header.Get(HeaderNonce)
+	signer := &hmac.Signer{Key: key}
+	if !signer.Verify(payload(node, ts, nonce), r.Header.Get(HeaderSignature)) {
+		log.Warnf("rejected tunnel of PoP %s from %s: bad signature", node, r.RemoteAddr)
+		return fmt.Errorf("bad signature")
+	}
+	if !checkFresh(ts, nonce, time.Now()) {
+		log.Warnf("rejected tunnel of PoP %s from %s: expired or replayed", node, r.RemoteAddr)
+		return fmt.Errorf("expired or replayed handshake")
+	}
+	return nil
+}
+
+// serve speaks HTTP/2 as the client over an accepted tunnel and keeps it
+// registered while the proxy answers pings
+func serve(ws *websocket.Conn) {
+	node := strings.ToLower(ws.Request().Header.Get(HeaderNode))
+	ws.PayloadType = websocket.BinaryFrame
+
+	cc, err := (&http2.Transport{}).NewClientConn(ws)
+	if err != nil {
+		log.Errorf("Failed to open tunnel of PoP %s: %v", node, err)
+		return
+	}
+	t := &tunnel{cc: cc, client: &http.Client{Transport: cc}}
+	register(node, t)
+	log.Infof("PoP %s connected through a tunnel from %s", node, ws.Request().RemoteAddr)
+
+	defer func() {
+		unregister(node, t)
+		cc.Close()
+		log.Infof("Tunnel of PoP %s closed", node)
+	}()
+
+	ticker := time.NewTicker(constant.TunnelPingInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if cc.State().Closed {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), constant.TunnelPingTimeout)
+		err := cc.Ping(ctx)
+		cancel()
+		if err != nil {
+			log.Warnf("Tunnel of PoP %s stopped answering: %v", node, err)
+			return
+		}
+	}
+}
@@ -0,0 +1,81 @@
+// Package tunnel lets proxies behind NAT or firewalls dial out to the
+// frontend. The proxy opens a WebSocket to the frontend and then serves
+// HTTP/2 over it, with the frontend acting as the client. Requests over
+// the tunnel are signed and verified like direct ones.
+package tunnel
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/LaunchPad-Network/NetPeek/constant"
+	"github.com/LaunchPad-Network/NetPeek/internal/logger"
+)
+
+var log = logger.New("Tunnel")
+
+// Headers authenticating a proxy opening a tunnel
+const (
+	HeaderNode      = "X-NetPeek-Node"
+	HeaderTs        = "X-NetPeek-Ts"
+	HeaderNonce     = "X-NetPeek-Nonce"
+	HeaderSignature = "X-NetPeek-Signature"
+)
+
+// Path is where the frontend accepts tunnels. It is below /api, which
+// skips the cookie check.
+const Path = "/api/tunnel"
+
+// payload is the string a proxy signs with its tunnel secret
+func payload(node, ts, nonce string) []byte {
+	return []byte(strings.Join([]string{"netpeek-tunnel-v1", node, ts, nonce}, "\n"))
+}
+
+func newNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// validity is how long a tunnel handshake may be used
+func validity() time.Duration {
+	return constant.ProxyReqSignValidityDuration + constant.ProxyReqSignMaxClockSkew
+}
+
+// nonces remembers recent handshake nonces so they cannot be replayed
+var nonces = struct {
+	sync.Mutex
+	seen map[string]time.Time
+}{seen: make(map[string]time.Time)}
+
+// checkFresh verifies the timestamp of a handshake and that its nonce
+// was not used before
+func checkFresh(ts, nonce string, now time.Time) bool {
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || nonce == "" {
+		return false
+	}
+	t := time.Unix(sec, 0)
+	if now.Sub(t) > validity() || t.Sub(now) > constant.ProxyReqSignMaxClockSkew {
+		return false
+	}
+
+	nonces.Lock()
+	defer nonces.Unlock()
+	for n, expires := range nonces.seen {
+		if now.After(expires) {
+			delete(nonces.seen, n)
+		}
+	}
+	if _, ok := nonces.seen[nonce]; ok {
+		return false
+	}
+	nonces.seen[nonce] = t.Add(validity())
+	return true
+}
@@ -0,0 +1,93 @@
+package tunnel
+
+import (
+	"context"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTunnel(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	secretOf := func(node string) (string, bool) {
+		return hex.EncodeToString(secret), node == "node1"
+	}
+	frontend := httptest.NewServer(Handler(secretOf))
+	defer frontend.Close()
+
+	proxy := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "hello "+r.URL.Query().Get("q"))
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go Serve(ctx, DialOptions{
+		URL:    "ws" + strings.TrimPrefix(frontend.URL, "http") + Path,
+		Node:   "node1",
+		Secret: secret,
+	}, proxy)
+
+	var client *http.Client
+	for i := 0; i < 100; i++ {
+		if c, ok := Client("NODE1"); ok {
+			client = c
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if client == nil {
+		t.Fatal("proxy did not connect")
+	}
+
+	resp, err := client.Get("http://node1/bird?q=world")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "hello world" {
+		t.Errorf("got %q through the tunnel", body)
+	}
+	if nodes := Connected(); len(nodes) != 1 || nodes[0] != "node1" {
+		t.Errorf("Connected() = %v", nodes)
+	}
+}
+
+func TestTunnelRejected(t *testing.T) {
+	secretOf := func(node string) (string, bool) {
+		return hex.EncodeToString([]byte("0123456789abcdef0123456789abcdef")), true
+	}
+	frontend := httptest.NewServer(Handler(secretOf))
+	defer frontend.Close()
+
+	_, err := dial(DialOptions{
+		URL:    "ws" + strings.TrimPrefix(frontend.URL, "http") + Path,
+		Node:   "node2",
+		Secret: []byte("another secret of 32 bytes......"),
+	})
+	if err == nil {
+		t.Fatal("tunnel with a wrong secret was accepted")
+	}
+	if IsConnected("node2") {
+		t.Error("rejected PoP is listed as connected")
+	}
+}
+
+func TestCheckFresh(t *testing.T) {
+	now := time.Unix(1_800_000_000, 0)
+	if !checkFresh("1800000000", "n1", now) {
+		t.Error("fresh handshake rejected")
+	}
+	if checkFresh("1800000000", "n1", now) {
+		t.Error("replayed handshake accepted")
+	}
+	if checkFresh("1799999000", "n2", now) {
+		t.Error("expired handshake accepted")
+	}
+	if checkFresh("1800001000", "n3", now) {
+		t.Error("future handshake accepted")
+	}
+}
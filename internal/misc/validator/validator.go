@@ -76,3 +76,11 @@ func IsValidProtocol(s string) bool {
 	reg := regexp.MustCompile(`^[0-9A-Za-z_-]+$`)
 	return reg.MatchString(s)
 }
+
+var sourceNameRe = regexp.MustCompile(`^[0-9A-Za-z][0-9A-Za-z_.-]{0,31}$`)
+
+// IsValidSourceName 判断给定字符串是否为合法的探测源名称：以字母或数字开头，
+// 最多 32 个字母、数字、'_'、'-' 或 '.'
+func IsValidSourceName(s string) bool {
+	return sourceNameRe.MatchString(s)
+}
@@ -0,0 +1,112 @@
+package frontend
+
+import (
+	"slices"
+
+	"github.com/LaunchPad-Network/NetPeek/constant"
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/capabilities"
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/proxyapi"
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/proxyreq"
+	"github.com/patrickmn/go-cache"
+)
+
+// queryMode is an entry of the query form on the summary page
+type queryMode struct {
+	Value string
+	Label string
+}
+
+var queryModes = []queryMode{
+	{capabilities.ToolRoute, "show route for [ip/prefix]"},
+	{capabilities.ToolFilter, "filtered routes [protocol]"},
+	{capabilities.ToolTraceroute, "traceroute [ip|domain]"},
+	{capabilities.ToolTraceroute4, "traceroute4 [ip|domain]"},
+	{capabilities.ToolTraceroute6, "traceroute6 [ip|domain]"},
+	{capabilities.ToolPing, "ping [ip|domain]"},
+}
+
+// getCapabilities returns what a PoP offers, or nil for proxies that
+// cannot tell
+func (f *Frontend) getCapabilities(id string) *capabilities.Capabilities {
+	if v, found := f.capabilities.Get(id); found {
+		return v.(*capabilities.Capabilities)
+	}
+
+	caps, err := proxyreq.CapabilitiesRequest(id)
+	if err != nil {
+		log.Debugf("Failed to fetch capabilities of %s: %v", id, err)
+		f.capabilities.Set(id, (*capabilities.Capabilities)(nil), constant.CapabilitiesFailedCacheDuration)
+		return nil
+	}
+
+	f.capabilities.Set(id, caps, cache.DefaultExpiration)
+	return caps
+}
+
+// supports reports whether a PoP offers a tool. PoPs with unknown
+// capabilities are assumed to offer everything.
+func (f *Frontend) supports(id, tool string) bool {
+	caps := f.getCapabilities(id)
+	return caps == nil || caps.Supports(tool)
+}
+
+// queryModes returns the query modes a PoP supports
+func (f *Frontend) queryModes(id string) []queryMode {
+	var modes []queryMode
+	for _, mode := range queryModes {
+		if f.supports(id, mode.Value) {
+			modes = append(modes, mode)
+		}
+	}
+	return modes
+}
+
+// probeModes are the traceroute probe protocols besides ICMP, which is
+// always offered with traceroute
+var probeModes = []queryMode{
+	{proxyapi.ProbeTCP, "TCP probes"},
+	{proxyapi.ProbeUDP, "UDP probes"},
+}
+
+// probeProtocols returns the traceroute probe protocols a PoP offers for
+// the query form
+func (f *Frontend) probeProtocols(id string) []queryMode {
+	if !f.supports(id, capabilities.ToolTraceroute) {
+		return nil
+	}
+	caps := f.getCapabilities(id)
+	if caps == nil || caps.Traceroute == nil || caps.Traceroute.Protocols == nil {
+		return probeModes
+	}
+
+	var modes []queryMode
+	for _, mode := range probeModes {
+		if slices.Contains(caps.Traceroute.Protocols, mode.Value) {
+			modes = append(modes, mode)
+		}
+	}
+	return modes
+}
+
+// traceLimits returns the probing settings of a PoP's traceroute, or nil
+// if they are unknown
+func (f *Frontend) traceLimits(id string) *capabilities.TracerouteLimits {
+	if !f.supports(id, capabilities.ToolTraceroute) {
+		return nil
+	}
+	caps := f.getCapabilities(id)
+	if caps == nil {
+		return nil
+	}
+	return caps.Traceroute
+}
+
+// sources returns the source profiles a PoP offers traceroutes and pings
+// from
+func (f *Frontend) sources(id string) []capabilities.Source {
+	caps := f.getCapabilities(id)
+	if caps == nil || (caps.Traceroute == nil && caps.Ping == nil) {
+		return nil
+	}
+	return caps.Sources
+}
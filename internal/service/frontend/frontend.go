@@ -1,20 +1,36 @@
 package frontend
 
 import (
+	"fmt"
+	"html/template"
+	"regexp"
+	"time"
+
+	"github.com/LaunchPad-Network/NetPeek/constant"
 	"github.com/LaunchPad-Network/NetPeek/internal/logger"
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/proxyreqsign"
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/tlsconfig"
 	"github.com/LaunchPad-Network/NetPeek/internal/router"
 	"github.com/gin-gonic/gin"
+	"github.com/patrickmn/go-cache"
+	"github.com/spf13/viper"
 )
 
 var log = logger.New("Frontend")
 
 type Frontend struct {
-	engine *gin.Engine
+	engine       *gin.Engine
+	instances    *cache.Cache
+	capabilities *cache.Cache
+	// fragments are the partial templates streamed to pages over SSE
+	fragments *template.Template
 }
 
 func New() *Frontend {
 	f := &Frontend{
-		engine: router.SetupRouter(),
+		engine:       router.SetupRouter(),
+		instances:    cache.New(constant.InstancesCacheDuration, time.Minute),
+		capabilities: cache.New(constant.CapabilitiesCacheDuration, time.Minute),
 	}
 	f.setup()
 	return f
@@ -25,6 +41,32 @@ func (f *Frontend) Engine() *gin.Engine {
 }
 
 func SetupRouter() *gin.Engine {
+	if err := proxyreqsign.LoadSigningKey(); err != nil {
+		log.Fatal(err)
+	}
 	f := New()
 	return f.Engine()
 }
+
+// CheckConfig validates the frontend configuration without serving
+func CheckConfig() error {
+	if err := proxyreqsign.LoadSigningKey(); err != nil {
+		return err
+	}
+	fmt.Printf("Signing key: %s (%s)\n", proxyreqsign.SigningKeyID(), proxyreqsign.Algorithm())
+
+	if viper.GetString("servers.pull_url") == "" {
+		return fmt.Errorf("servers.pull_url is not set")
+	}
+	if _, err := regexp.Compile(viper.GetString("frontend.name_filter")); err != nil {
+		return fmt.Errorf("invalid frontend.name_filter: %w", err)
+	}
+	if _, err := tlsconfig.Client(tlsconfig.ClientOptions{
+		CAFile:   viper.GetString("servers.tls.ca_file"),
+		CertFile: viper.GetString("servers.tls.cert_file"),
+		KeyFile:  viper.GetString("servers.tls.key_file"),
+	}); err != nil {
+		return fmt.Errorf("invalid servers.tls: %w", err)
+	}
+	return nil
+}
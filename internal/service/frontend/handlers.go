@@ -5,8 +5,10 @@ import (
 	"net/url"
 
 	"github.com/LaunchPad-Network/NetPeek/internal/config"
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/proxyreq"
 	"github.com/LaunchPad-Network/NetPeek/internal/misc/render"
 	"github.com/LaunchPad-Network/NetPeek/internal/misc/serverslist"
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/tunnel"
 	"github.com/LaunchPad-Network/NetPeek/internal/service/frontend/assets"
 	"github.com/gin-gonic/gin"
 )
@@ -30,8 +32,10 @@ func (f *Frontend) handleHome(c *gin.Context) {
 	viewMode, err := c.Cookie("lg_view_mode")
 	if err == nil {
 		if viewMode == "list" {
+			servers := serverslist.GetServersList()
 			render.RenderHTML(c, http.StatusOK, "list.tmpl", gin.H{
-				"ServersList": serverslist.GetServersList(),
+				"ServersList": servers,
+				"Connections": connections(servers),
 			})
 			return
 		}
@@ -84,3 +88,19 @@ func (f *Frontend) cookieTestStage2(c *gin.Context) {
 
 	c.Redirect(http.StatusFound, redirect)
 }
+
+// connections describes how the frontend reaches each PoP
+func connections(servers []*serverslist.Server) map[string]string {
+	conns := make(map[string]string, len(servers))
+	for _, srv := range servers {
+		switch {
+		case !proxyreq.UsesTunnel(srv.Id):
+			conns[srv.Id] = "Direct"
+		case tunnel.IsConnected(srv.Id):
+			conns[srv.Id] = "Tunnel"
+		default:
+			conns[srv.Id] = "Tunnel (disconnected)"
+		}
+	}
+	return conns
+}
@@ -1,16 +1,26 @@
 package frontend
 
 import (
-	"html/template"
+	"errors"
 	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
 	"strings"
 
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/asnlookup"
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/bird"
 	"github.com/LaunchPad-Network/NetPeek/internal/misc/birdformatter"
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/capabilities"
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/protocolparser"
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/proxyapi"
 	"github.com/LaunchPad-Network/NetPeek/internal/misc/proxyreq"
 	"github.com/LaunchPad-Network/NetPeek/internal/misc/render"
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/routepager"
 	"github.com/LaunchPad-Network/NetPeek/internal/misc/serverslist"
 	"github.com/LaunchPad-Network/NetPeek/internal/misc/validator"
 	"github.com/gin-gonic/gin"
+	"github.com/lfcypo/viperx"
 )
 
 func (f *Frontend) handleProtocol(c *gin.Context) {
@@ -31,110 +41,467 @@ func (f *Frontend) handleProtocol(c *gin.Context) {
 		return
 	}
 
-	cmd := "show protocols all '" + p + "'"
-	resp, err := proxyreq.BirdRequest(id, cmd)
-	if err != nil || strings.Contains(resp, "syntax error, unexpected CF_SYM_UNDEFINED") {
-		if err != nil {
-			log.Errorf("Failed to fetch protocol details for %s (%s): %v", id, p, err)
+	instance, ok := f.selectInstance(id, c.Query("instance"))
+	if !ok {
+		f.renderErr(c, http.StatusNotFound,
+			"BIRD instance not found.", "/detail/"+id, "Go back to Summary")
+		return
+	}
+
+	req := proxyapi.New(proxyapi.TypeProtocol, instance, proxyapi.Args{Protocol: p})
+	cmd, _ := req.BirdCommand()
+	resp, err := proxyreq.Query(id, req)
+	if err != nil {
+		if errors.Is(err, bird.ErrUnknownProtocol) || errors.Is(err, bird.ErrSyntax) {
+			f.renderErr(c, http.StatusNotFound,
+				"Protocol not found.", summaryPath(id, instance), "Go back to Summary")
+			return
 		}
+		log.Errorf("Failed to fetch protocol details for %s (%s): %v", id, p, err)
 		f.renderErr(c, http.StatusInternalServerError,
-			"Invalid protocol name or failed to fetch protocol details. Please try again later.",
-			"/detail/"+id, "Go back to Summary")
+			birdErrMessage(err, "Failed to fetch protocol details. Please try again later."),
+			summaryPath(id, instance), "Go back to Summary")
+		return
+	}
+
+	proto, err := protocolparser.Parse(resp)
+	if err != nil {
+		log.Debugf("Failed to parse protocol details for %s (%s): %v", id, p, err)
+		f.renderBird(c, id, instance, p, cmd, resp)
 		return
 	}
 
-	f.renderBird(c, id, p, cmd, resp)
+	filterPath := "/detail/" + id + "?mode=filter&q=" + url.QueryEscape(p)
+	if instance != "" {
+		filterPath += "&instance=" + url.QueryEscape(instance)
+	}
+
+	render.RenderHTML(c, http.StatusOK, "protocol.tmpl", gin.H{
+		"Title":       id + " - " + p,
+		"Server":      srv,
+		"Instance":    instance,
+		"SummaryPath": summaryPath(id, instance),
+		"FilterPath":  filterPath,
+		"Command":     cmd,
+		"Protocol":    proto,
+		"Raw": birdformatter.SmartFormatter(strings.TrimSpace(resp), birdformatter.SmartFormatterOptions{
+			Server:          srv,
+			Instance:        instance,
+			CurrentProtocol: p,
+		}),
+	})
 }
 
-func (f *Frontend) handleRoute(c *gin.Context, id, q string) {
-	isV4, isV6 := validator.IsIP(q)
-	isV4CIDR, isV6CIDR := validator.IsCIDR(q)
-	if !(isV4 || isV6 || isV4CIDR || isV6CIDR) {
-		f.renderModeErr(c, id, "Invalid IP address or CIDR notation.")
-		return
+// modeQuery is a validated query of a detail mode whose output is shown
+// as BIRD-style text
+type modeQuery struct {
+	Mode     string
+	Q        string
+	Instance string
+	Title    string
+	// Command is shown to the visitor, the proxy builds it from Request
+	Command string
+	Request *proxyapi.Request
+	Page    int
+}
+
+// newBirdQuery returns the mode query of a BIRD request
+func newBirdQuery(mode, q, title string, req *proxyapi.Request) *modeQuery {
+	cmd, _ := req.BirdCommand()
+	return &modeQuery{
+		Mode:     mode,
+		Q:        q,
+		Instance: req.Instance,
+		Title:    title,
+		Command:  cmd,
+		Request:  req,
 	}
+}
 
-	cmd := "show route for " + q + " all"
-	resp, err := proxyreq.BirdRequest(id, cmd)
-	if err != nil {
-		log.Errorf("Failed to fetch route for %s (%s): %v", id, q, err)
-		f.renderModeErr(c, id, "Failed to fetch information.")
-		return
+// pageOptions selects the requested page of route listings. Other output
+// is shown in one piece.
+func (mq *modeQuery) pageOptions() routepager.Options {
+	if mq.isTraceroute() {
+		return routepager.Options{}
 	}
-	if strings.Contains(resp, "syntax error, unexpected CF_SYM_UNDEFINED") {
-		f.renderModeErr(c, id, "Invalid parameter. Please try again later.")
-		return
+	return routepager.Options{
+		Page: mq.Page,
+		Size: viperx.GetInt("frontend.routes_per_page", 100),
 	}
+}
 
-	f.renderBird(c, id, "show route for "+q, cmd, resp)
+// parsePage reads the page number of a listing, starting at 1
+func parsePage(c *gin.Context) int {
+	page, err := strconv.Atoi(c.Query("page"))
+	if err != nil || page < 1 {
+		return 1
+	}
+	return page
 }
 
-func (f *Frontend) handleFilter(c *gin.Context, id, q string) {
-	if !validator.IsValidProtocol(q) {
-		f.renderModeErr(c, id, "Invalid protocol name.")
-		return
+// pageURL links to another page of the current listing
+func pageURL(c *gin.Context, page int) string {
+	q := c.Request.URL.Query()
+	q.Set("page", strconv.Itoa(page))
+	return c.Request.URL.Path + "?" + q.Encode()
+}
+
+// pageLinks returns the links to the previous and next page, if any
+func pageLinks(c *gin.Context, mq *modeQuery, hasNext bool) (string, string) {
+	prev, next := "", ""
+	if mq.Page > 1 {
+		prev = pageURL(c, mq.Page-1)
+	}
+	if hasNext {
+		next = pageURL(c, mq.Page+1)
 	}
+	return prev, next
+}
 
-	cmd := "show route filtered all protocol '" + q + "'"
-	resp, err := proxyreq.BirdRequest(id, cmd)
-	if err != nil || strings.Contains(resp, "syntax error, unexpected CF_SYM_UNDEFINED") {
-		if err != nil {
-			log.Errorf("Failed to fetch filtered routes for %s (%s): %v", id, q, err)
+// parseModeQuery validates a detail mode query. If the query is invalid it
+// returns a message for the visitor instead.
+func (f *Frontend) parseModeQuery(id, instance, mode, q string) (*modeQuery, string) {
+	switch mode {
+	case "route":
+		isV4, isV6 := validator.IsIP(q)
+		isV4CIDR, isV6CIDR := validator.IsCIDR(q)
+		if !(isV4 || isV6 || isV4CIDR || isV6CIDR) {
+			return nil, "Invalid IP address or CIDR notation."
 		}
-		f.renderModeErr(c, id, "Failed to fetch information. Please try again later.")
-		return
+		req := proxyapi.New(proxyapi.TypeRoute, f.instanceForFamily(id, instance, isV6 || isV6CIDR), proxyapi.Args{Prefix: q, All: true})
+		return newBirdQuery(mode, q, "show route for "+q, req), ""
+	case "filter":
+		if !validator.IsValidProtocol(q) {
+			return nil, "Invalid protocol name."
+		}
+		req := proxyapi.New(proxyapi.TypeFilter, instance, proxyapi.Args{Protocol: q})
+		return newBirdQuery(mode, q, "filtered routes "+q, req), ""
+	case "traceroute", "traceroute4", "traceroute6":
+		isV4, isV6 := validator.IsIP(q)
+		isDomain := validator.IsDomain(q)
+		family := traceFamilies[mode]
+		if !(isV4 || isV6 || isDomain) ||
+			(family == proxyapi.FamilyIPv4 && isV6) || (family == proxyapi.FamilyIPv6 && isV4) {
+			return nil, "Invalid IP address or domain name."
+		}
+		return &modeQuery{
+			Mode:    mode,
+			Q:       q,
+			Title:   mode + " " + q,
+			Command: mode + " " + q,
+			Request: proxyapi.New(proxyapi.TypeTraceroute, "", proxyapi.Args{Target: q, Family: family}),
+		}, ""
+	case "ping":
+		isV4, isV6 := validator.IsIP(q)
+		isDomain := validator.IsDomain(q)
+		if !(isV4 || isV6 || isDomain) {
+			return nil, "Invalid IP address or domain name."
+		}
+		return &modeQuery{
+			Mode:    mode,
+			Q:       q,
+			Title:   "ping " + q,
+			Command: "ping " + q,
+			Request: proxyapi.New(proxyapi.TypePing, "", proxyapi.Args{Target: q}),
+		}, ""
+	default:
+		return nil, "Invalid request."
 	}
+}
+
+// traceFamilies maps the traceroute modes to the address family they trace
+var traceFamilies = map[string]string{
+	"traceroute":  proxyapi.FamilyAny,
+	"traceroute4": proxyapi.FamilyIPv4,
+	"traceroute6": proxyapi.FamilyIPv6,
+}
+
+// isTraceroute reports whether the query is a traceroute of any family
+func (mq *modeQuery) isTraceroute() bool {
+	_, ok := traceFamilies[mq.Mode]
+	return ok
+}
 
-	f.renderBird(c, id, "filtered routes "+q, cmd, resp)
+// traceProbeFlags are the traceroute(8) options shown for probe protocols
+var traceProbeFlags = map[string]string{
+	proxyapi.ProbeICMP: "-I",
+	proxyapi.ProbeTCP:  "-T",
+	proxyapi.ProbeUDP:  "-U",
 }
 
-func (f *Frontend) handleTraceroute(c *gin.Context, id, q string) {
-	isV4, isV6 := validator.IsIP(q)
-	isDomain := validator.IsDomain(q)
-	if !(isV4 || isV6 || isDomain) {
-		f.renderModeErr(c, id, "Invalid IP address or domain name.")
+// setProbe selects the probe protocol and port of a traceroute. Empty
+// values keep the proxy's defaults. If they are invalid it returns a
+// message for the visitor.
+func (mq *modeQuery) setProbe(probe, port string) string {
+	if !mq.isTraceroute() || (probe == "" && port == "") {
+		return ""
+	}
+	flag, ok := traceProbeFlags[probe]
+	if probe != "" && !ok {
+		return "Invalid probe protocol."
+	}
+
+	args := &mq.Request.Args
+	args.Probe = probe
+	cmd := mq.Mode
+	if flag != "" {
+		cmd += " " + flag
+	}
+	if port != "" && probe != proxyapi.ProbeICMP {
+		n, err := strconv.Atoi(port)
+		if err != nil || n < 1 || n > 65535 {
+			return "Invalid port."
+		}
+		if probe == "" {
+			return "A port needs a TCP or UDP probe."
+		}
+		args.Port = n
+		cmd += " -p " + port
+	}
+	mq.Command = cmd + " " + mq.Q
+	return ""
+}
+
+// setBounds sets the hops and probes per hop of a traceroute, which must
+// be within the limits of the PoP if it tells them. The proxy checks them
+// again. Empty values keep the
+// proxy's defaults. If they are invalid it returns a message for the
+// visitor.
+func (f *Frontend) setBounds(id string, mq *modeQuery, hops, count string) string {
+	if !mq.isTraceroute() || (hops == "" && count == "") {
+		return ""
+	}
+	maxHops, maxCount := 0, 0
+	if limits := f.traceLimits(id); limits != nil {
+		maxHops, maxCount = limits.MaxHops, limits.MaxCount
+	}
+
+	args := &mq.Request.Args
+	flags := ""
+	if hops != "" {
+		n, err := strconv.Atoi(hops)
+		if err != nil || n < 1 || (maxHops > 0 && n > maxHops) {
+			return "Invalid number of hops."
+		}
+		args.MaxHops = n
+		flags += "-m " + hops + " "
+	}
+	if count != "" {
+		n, err := strconv.Atoi(count)
+		if err != nil || n < 1 || (maxCount > 0 && n > maxCount) {
+			return "Invalid number of probes per hop."
+		}
+		args.Count = n
+		flags += "-q " + count + " "
+	}
+	mq.Command = strings.TrimSuffix(mq.Command, mq.Q) + flags + mq.Q
+	return ""
+}
+
+// sourceFlags are the options of traceroute(8) and ping(8) shown for the
+// source of the probes
+var sourceFlags = map[string]string{
+	"traceroute":  "-s",
+	"traceroute4": "-s",
+	"traceroute6": "-s",
+	"ping":        "-I",
+}
+
+// setSource selects the source profile of a traceroute or ping, which
+// must be one the PoP offers. An empty name keeps the proxy's default. If
+// it is invalid it returns a message for the visitor. The title names the
+// profile, the command shows its address once the family is known.
+func (f *Frontend) setSource(id string, mq *modeQuery, source string) string {
+	flag, ok := sourceFlags[mq.Mode]
+	if !ok || source == "" {
+		return ""
+	}
+	i := slices.IndexFunc(f.sources(id), func(s capabilities.Source) bool { return s.Name == source })
+	if i < 0 {
+		return "This source is not offered by this PoP."
+	}
+
+	mq.Request.Args.Source = source
+	mq.Title += " from " + source
+	if addr := sourceAddress(f.sources(id)[i], mq.Q, mq.Request.Args.Family); addr != "" {
+		mq.Command = strings.TrimSuffix(mq.Command, mq.Q) + flag + " " + addr + " " + mq.Q
+	}
+	return ""
+}
+
+// sourceAddress returns the address probes to target are sent from,
+// following the proxy: the family is the one of an address target, the
+// requested one or the only one the profile has. It is "" if the family
+// is only known after the proxy resolved the target.
+func sourceAddress(src capabilities.Source, target, family string) string {
+	isV4, isV6 := validator.IsIP(target)
+	switch {
+	case isV4 || family == proxyapi.FamilyIPv4:
+		return src.IPv4
+	case isV6 || family == proxyapi.FamilyIPv6:
+		return src.IPv6
+	case src.IPv6 == "":
+		return src.IPv4
+	case src.IPv4 == "":
+		return src.IPv6
+	default:
+		return ""
+	}
+}
+
+// streams reports whether the output of the query is shown as it arrives.
+// Ping results are only complete once all probes are answered.
+func (mq *modeQuery) streams() bool {
+	return mq.Mode != "ping"
+}
+
+// errMessage describes a failed query to the visitor. Errors caused by
+// the query itself are not worth logging.
+func (mq *modeQuery) errMessage(id string, err error) string {
+	if errors.Is(err, proxyreq.ErrNotConnected) {
+		return "This PoP is currently not connected. Please try again later."
+	}
+	if errors.Is(err, proxyreq.ErrBusy) {
+		return "This PoP is busy with other measurements. Please try again later."
+	}
+	switch mq.Mode {
+	case "route":
+		if errors.Is(err, bird.ErrSyntax) {
+			return "Invalid parameter."
+		}
+		log.Errorf("Failed to fetch route for %s (%s): %v", id, mq.Q, err)
+		return birdErrMessage(err, "Failed to fetch information.")
+	case "filter":
+		if errors.Is(err, bird.ErrUnknownProtocol) || errors.Is(err, bird.ErrSyntax) {
+			return "Protocol not found."
+		}
+		log.Errorf("Failed to fetch filtered routes for %s (%s): %v", id, mq.Q, err)
+		return birdErrMessage(err, "Failed to fetch information. Please try again later.")
+	case "ping":
+		log.Errorf("Failed to ping from %s (%s): %v", id, mq.Q, err)
+		return "Failed to perform ping."
+	default:
+		log.Errorf("Failed to perform %s for %s (%s): %v", mq.Mode, id, mq.Q, err)
+		return "Failed to perform traceroute."
+	}
+}
+
+func (f *Frontend) handleBirdMode(c *gin.Context, id string, mq *modeQuery) {
+	body, err := proxyreq.QueryStream(c.Request.Context(), id, mq.Request)
+	if err != nil {
+		f.renderModeErr(c, id, mq.errMessage(id, err))
 		return
 	}
+	defer body.Close()
 
-	resp, err := proxyreq.TracerouteHTMLRequest(id, q)
-	if err == nil && strings.HasPrefix(resp, "<table") {
-		srv := serverslist.GetServerByID(id)
-		render.RenderHTML(c, http.StatusOK, "tracerouteh.tmpl", gin.H{
-			"Title":   id + " - " + q,
-			"Server":  srv,
-			"Command": "traceroute " + q,
-			"Raw":     template.HTML(resp),
-		})
+	var resp strings.Builder
+	res, err := routepager.Copy(body, mq.pageOptions(), func(s string) error {
+		resp.WriteString(s)
+		return nil
+	})
+	if err != nil {
+		f.renderModeErr(c, id, mq.errMessage(id, err))
 		return
 	}
 
-	resp, err = proxyreq.TracerouteRequest(id, q)
+	data := f.birdData(id, mq.Instance, mq.Title, mq.Command, resp.String())
+	data["Truncated"] = res.Truncated
+	data["PrevURL"], data["NextURL"] = pageLinks(c, mq, res.HasNext)
+	render.RenderHTML(c, http.StatusOK, "bird.tmpl", data)
+}
+
+func (f *Frontend) handleTraceroute(c *gin.Context, id string, mq *modeQuery) {
+	res, err := proxyreq.TracerouteRequest(id, mq.Request)
 	if err != nil {
-		log.Errorf("Failed to perform traceroute for %s (%s): %v", id, q, err)
-		f.renderModeErr(c, id, "Failed to perform traceroute.")
+		f.renderModeErr(c, id, mq.errMessage(id, err))
 		return
 	}
 
-	f.renderBird(c, id, "traceroute "+q, "traceroute "+q, resp)
+	render.RenderHTML(c, http.StatusOK, "traceroute.tmpl", gin.H{
+		"Title":   id + " - " + mq.Title,
+		"Server":  serverslist.GetServerByID(id),
+		"Command": mq.Command,
+		"Result":  res,
+		"Hops":    tracerouteHops(res),
+	})
 }
 
-func (f *Frontend) renderBird(c *gin.Context, id, q, cmd, raw string) {
+// tracerouteHop is a hop of a traceroute as shown to the visitor
+type tracerouteHop struct {
+	proxyapi.TracerouteHop
+	ASName string
+	// Crossing marks the first hop in another AS than the hop before
+	Crossing bool
+}
+
+// hopAnnotator adds the AS names to the hops of a traceroute one by one
+// and marks where the path crosses from one network to another
+type hopAnnotator struct {
+	lastASN uint32
+}
+
+func (a *hopAnnotator) annotate(hop proxyapi.TracerouteHop) tracerouteHop {
+	h := tracerouteHop{TracerouteHop: hop}
+	if hop.ASN != 0 {
+		h.ASName = asnlookup.Lookup.Lookup(strconv.FormatUint(uint64(hop.ASN), 10))
+		h.Crossing = a.lastASN != 0 && hop.ASN != a.lastASN
+		a.lastASN = hop.ASN
+	}
+	return h
+}
+
+// tracerouteHops annotates all hops of a traceroute
+func tracerouteHops(res *proxyapi.TracerouteResult) []tracerouteHop {
+	var a hopAnnotator
+	hops := make([]tracerouteHop, 0, len(res.Hops))
+	for _, hop := range res.Hops {
+		hops = append(hops, a.annotate(hop))
+	}
+	return hops
+}
+
+func (f *Frontend) handlePing(c *gin.Context, id string, mq *modeQuery) {
+	res, err := proxyreq.PingRequest(id, mq.Request.Args)
+	if err != nil {
+		f.renderModeErr(c, id, mq.errMessage(id, err))
+		return
+	}
+
+	render.RenderHTML(c, http.StatusOK, "ping.tmpl", gin.H{
+		"Title":   id + " - " + mq.Title,
+		"Server":  serverslist.GetServerByID(id),
+		"Command": mq.Command,
+		"Result":  res,
+	})
+}
+
+func (f *Frontend) renderBird(c *gin.Context, id, instance, q, cmd, raw string) {
+	render.RenderHTML(c, http.StatusOK, "bird.tmpl", f.birdData(id, instance, q, cmd, raw))
+}
+
+func (f *Frontend) birdData(id, instance, q, cmd, raw string) gin.H {
 	srv := serverslist.GetServerByID(id)
 
+	return gin.H{
+		"Title":       id + " - " + q,
+		"Server":      srv,
+		"Instance":    instance,
+		"SummaryPath": summaryPath(id, instance),
+		"Command":     cmd,
+		"Raw":         birdformatter.SmartFormatter(strings.TrimSpace(raw), formatterOptions(srv, instance, q, cmd)),
+	}
+}
+
+func formatterOptions(srv *serverslist.Server, instance, q, cmd string) birdformatter.SmartFormatterOptions {
 	protocol := ""
-	isRoute := strings.HasPrefix(cmd, "show route")
 	if strings.HasPrefix(cmd, "show protocols") {
 		protocol = q
 	}
 
-	render.RenderHTML(c, http.StatusOK, "bird.tmpl", gin.H{
-		"Title":   id + " - " + q,
-		"Server":  srv,
-		"Command": cmd,
-		"Raw": birdformatter.SmartFormatter(strings.TrimSpace(raw), birdformatter.SmartFormatterOptions{
-			Server:          srv,
-			CurrentProtocol: protocol,
-			IsRouteOutput:   isRoute,
-		}),
-	})
+	return birdformatter.SmartFormatterOptions{
+		Server:          srv,
+		Instance:        instance,
+		CurrentProtocol: protocol,
+		IsRouteOutput:   strings.HasPrefix(cmd, "show route"),
+	}
 }
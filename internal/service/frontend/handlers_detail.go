@@ -3,6 +3,8 @@ package frontend
 import (
 	"net/http"
 
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/capabilities"
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/proxyapi"
 	"github.com/LaunchPad-Network/NetPeek/internal/misc/proxyreq"
 	"github.com/LaunchPad-Network/NetPeek/internal/misc/render"
 	"github.com/LaunchPad-Network/NetPeek/internal/misc/serverslist"
@@ -20,14 +22,21 @@ func (f *Frontend) handleDetail(c *gin.Context) {
 		return
 	}
 
+	instance, ok := f.selectInstance(id, c.Query("instance"))
+	if !ok {
+		f.renderErr(c, http.StatusNotFound,
+			"BIRD instance not found.", "/detail/"+id, "Go back to Summary")
+		return
+	}
+
 	mode := c.Query("mode")
 	q := c.Query("q")
 	if mode != "" && q != "" {
-		f.handleDetailMode(c, id, mode, q)
+		f.handleDetailMode(c, id, instance, mode, q)
 		return
 	}
 
-	summaryResp, err := proxyreq.BirdRequest(id, "show protocols")
+	summaryResp, err := proxyreq.Query(id, proxyapi.New(proxyapi.TypeSummary, instance, proxyapi.Args{}))
 	if err != nil {
 		log.Errorf("Failed to fetch BGP summary for %s: %v", id, err)
 		f.renderErr(c, http.StatusInternalServerError,
@@ -43,22 +52,65 @@ func (f *Frontend) handleDetail(c *gin.Context) {
 		return
 	}
 
+	var status *capabilities.InstanceStatus
+	if caps := f.getCapabilities(id); caps != nil {
+		status = caps.Instance(instance)
+	}
+
 	render.RenderHTML(c, http.StatusOK, "summary.tmpl", gin.H{
-		"Title":        id,
-		"Server":       srv,
-		"SummaryTable": table,
+		"Title":         id,
+		"Server":        srv,
+		"Instance":      instance,
+		"Instances":     f.getInstances(id),
+		"Status":        status,
+		"Modes":         f.queryModes(id),
+		"Probes":        f.probeProtocols(id),
+		"Sources":       f.sources(id),
+		"TraceLimits":   f.traceLimits(id),
+		"Traceroute":    f.supports(id, capabilities.ToolTraceroute),
+		"ProtocolLinks": f.supports(id, capabilities.ToolProtocol),
+		"SummaryTable":  table,
 	})
 }
 
-func (f *Frontend) handleDetailMode(c *gin.Context, id, mode, q string) {
-	switch mode {
-	case "route":
-		f.handleRoute(c, id, q)
-	case "filter":
-		f.handleFilter(c, id, q)
-	case "traceroute":
-		f.handleTraceroute(c, id, q)
-	default:
-		f.renderModeErr(c, id, "Invalid request.")
+func (f *Frontend) handleDetailMode(c *gin.Context, id, instance, mode, q string) {
+	mq, msg := f.parseModeQuery(id, instance, mode, q)
+	if mq == nil {
+		f.renderModeErr(c, id, msg)
+		return
+	}
+	if msg := mq.setProbe(c.Query("probe"), c.Query("port")); msg != "" {
+		f.renderModeErr(c, id, msg)
+		return
+	}
+	if msg := f.setBounds(id, mq, c.Query("max_hops"), c.Query("count")); msg != "" {
+		f.renderModeErr(c, id, msg)
+		return
+	}
+	if msg := f.setSource(id, mq, c.Query("source")); msg != "" {
+		f.renderModeErr(c, id, msg)
+		return
+	}
+	if !f.supports(id, mode) {
+		f.renderModeErr(c, id, "This query is not supported by this PoP.")
+		return
+	}
+	mq.Page = parsePage(c)
+
+	if mode == "ping" {
+		f.handlePing(c, id, mq)
+		return
+	}
+
+	// Pages are streamed unless the visitor came through the no-JS fallback
+	if c.Query("stream") != "0" {
+		f.renderStream(c, id, mq)
+		return
+	}
+
+	if mq.isTraceroute() {
+		f.handleTraceroute(c, id, mq)
+		return
 	}
+	f.handleBirdMode(c, id, mq)
 }
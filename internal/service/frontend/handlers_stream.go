@@ -0,0 +1,203 @@
+package frontend
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/birdformatter"
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/proxyapi"
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/proxyreq"
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/render"
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/routepager"
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/serverslist"
+	"github.com/gin-gonic/gin"
+)
+
+// renderStream renders the page shell that loads the output of a query
+// from /stream/:id over SSE. Browsers without JS are sent to the
+// complete page instead.
+func (f *Frontend) renderStream(c *gin.Context, id string, mq *modeQuery) {
+	params := url.Values{}
+	params.Set("mode", mq.Mode)
+	params.Set("q", mq.Q)
+	if mq.Instance != "" {
+		params.Set("instance", mq.Instance)
+	}
+	if mq.Page > 1 {
+		params.Set("page", strconv.Itoa(mq.Page))
+	}
+	if args := mq.Request.Args; args.Probe != "" {
+		params.Set("probe", args.Probe)
+		if args.Port != 0 {
+			params.Set("port", strconv.Itoa(args.Port))
+		}
+	}
+	if hops := mq.Request.Args.MaxHops; hops != 0 {
+		params.Set("max_hops", strconv.Itoa(hops))
+	}
+	if count := mq.Request.Args.Count; count != 0 {
+		params.Set("count", strconv.Itoa(count))
+	}
+	if source := mq.Request.Args.Source; source != "" {
+		params.Set("source", source)
+	}
+
+	fallback := c.Request.URL.Query()
+	fallback.Set("stream", "0")
+
+	// The stream tells whether there is a next page, the link is shown then
+	prev, _ := pageLinks(c, mq, false)
+	next := ""
+	if mq.pageOptions().Size > 0 {
+		next = pageURL(c, mq.Page+1)
+	}
+
+	render.RenderHTML(c, http.StatusOK, "stream.tmpl", gin.H{
+		"Title":       id + " - " + mq.Title,
+		"Server":      serverslist.GetServerByID(id),
+		"Instance":    mq.Instance,
+		"SummaryPath": summaryPath(id, mq.Instance),
+		"Command":     mq.Command,
+		"Traceroute":  mq.isTraceroute(),
+		"StreamURL":   "/stream/" + id + "?" + params.Encode(),
+		"FallbackURL": "/detail/" + id + "?" + fallback.Encode(),
+		"PrevURL":     prev,
+		"NextURL":     next,
+	})
+}
+
+// handleStream relays the output of a query as server-sent events.
+// "output" events carry formatted HTML to append. Before the final "done"
+// event, "truncated" tells why the proxy cut off the output and "more"
+// that there is a next page. A "failure" event with a message for the
+// visitor ends the stream instead on errors.
+func (f *Frontend) handleStream(c *gin.Context) {
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+
+	id := c.Param("id")
+	srv := serverslist.GetServerByID(id)
+	if srv == nil {
+		streamFailure(c, "PoP Not found. Please try again later.")
+		return
+	}
+
+	instance, ok := f.selectInstance(id, c.Query("instance"))
+	if !ok {
+		streamFailure(c, "BIRD instance not found.")
+		return
+	}
+
+	mq, msg := f.parseModeQuery(id, instance, c.Query("mode"), c.Query("q"))
+	if mq == nil {
+		streamFailure(c, msg)
+		return
+	}
+	if msg := mq.setProbe(c.Query("probe"), c.Query("port")); msg != "" {
+		streamFailure(c, msg)
+		return
+	}
+	if msg := f.setBounds(id, mq, c.Query("max_hops"), c.Query("count")); msg != "" {
+		streamFailure(c, msg)
+		return
+	}
+	if msg := f.setSource(id, mq, c.Query("source")); msg != "" {
+		streamFailure(c, msg)
+		return
+	}
+	if !f.supports(id, mq.Mode) {
+		streamFailure(c, "This query is not supported by this PoP.")
+		return
+	}
+	if !mq.streams() {
+		streamFailure(c, "Invalid request.")
+		return
+	}
+	mq.Page = parsePage(c)
+
+	if mq.isTraceroute() {
+		f.streamTraceroute(c, id, mq)
+		return
+	}
+
+	body, err := proxyreq.QueryStream(c.Request.Context(), id, mq.Request)
+	if err != nil {
+		streamFailure(c, mq.errMessage(id, err))
+		return
+	}
+	defer body.Close()
+
+	options := formatterOptions(srv, mq.Instance, mq.Title, mq.Command)
+	res, err := routepager.Copy(body, mq.pageOptions(), func(chunk string) error {
+		text := strings.TrimSuffix(chunk, "\n")
+		c.SSEvent("output", string(birdformatter.SmartFormatter(text, options)))
+		c.Writer.Flush()
+		return nil
+	})
+	if err != nil {
+		if c.Request.Context().Err() == nil {
+			log.Errorf("Stream from %s (%s) broke off: %v", id, mq.Command, err)
+		}
+		streamFailure(c, "The connection to the PoP was lost.")
+		return
+	}
+
+	if res.Truncated != "" {
+		c.SSEvent("truncated", res.Truncated)
+	}
+	if res.HasNext {
+		c.SSEvent("more", "")
+	}
+	c.SSEvent("done", "")
+	c.Writer.Flush()
+}
+
+// streamTraceroute relays the document of a traceroute as it is measured.
+// A "summary" event describes the traceroute, then "output" events carry
+// the table rows of its hops.
+func (f *Frontend) streamTraceroute(c *gin.Context, id string, mq *modeQuery) {
+	var hops hopAnnotator
+	started := false
+	err := proxyreq.TracerouteEvents(c.Request.Context(), id, mq.Request, func(ev proxyapi.TracerouteEvent) error {
+		started = true
+		var event, name string
+		var data any
+		switch {
+		case ev.Result != nil:
+			event, name, data = "summary", "traceroute-summary", ev.Result
+		case ev.Hop != nil:
+			event, name, data = "output", "traceroute-hop", hops.annotate(*ev.Hop)
+		default:
+			return nil
+		}
+
+		var buf strings.Builder
+		if err := f.fragments.ExecuteTemplate(&buf, name, data); err != nil {
+			return err
+		}
+		c.SSEvent(event, buf.String())
+		c.Writer.Flush()
+		return nil
+	})
+	if err != nil {
+		if !started {
+			streamFailure(c, mq.errMessage(id, err))
+			return
+		}
+		if c.Request.Context().Err() == nil {
+			log.Errorf("Traceroute stream from %s (%s) broke off: %v", id, mq.Command, err)
+		}
+		streamFailure(c, "The connection to the PoP was lost.")
+		return
+	}
+
+	c.SSEvent("done", "")
+	c.Writer.Flush()
+}
+
+func streamFailure(c *gin.Context, msg string) {
+	c.SSEvent("failure", msg)
+	c.Writer.Flush()
+}
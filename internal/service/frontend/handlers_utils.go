@@ -1,8 +1,10 @@
 package frontend
 
 import (
+	"errors"
 	"net/http"
 
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/bird"
 	"github.com/LaunchPad-Network/NetPeek/internal/misc/render"
 	"github.com/gin-gonic/gin"
 )
@@ -18,3 +20,16 @@ func (f *Frontend) renderErr(c *gin.Context, code int, msg, back, backMsg string
 func (f *Frontend) renderModeErr(c *gin.Context, id, msg string) {
 	f.renderErr(c, http.StatusInternalServerError, msg, "/detail/"+id, "Go back to Summary")
 }
+
+// birdErrMessage describes BIRD errors that are meaningful to the visitor,
+// falling back to a generic message for everything else.
+func birdErrMessage(err error, fallback string) string {
+	switch {
+	case errors.Is(err, bird.ErrTableNotFound):
+		return "Routing table not found."
+	case errors.Is(err, bird.ErrAccessRestricted):
+		return "This query is not allowed on this PoP."
+	default:
+		return fallback
+	}
+}
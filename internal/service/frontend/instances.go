@@ -0,0 +1,71 @@
+package frontend
+
+import (
+	"net/url"
+
+	"github.com/LaunchPad-Network/NetPeek/constant"
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/bird"
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/proxyreq"
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/validator"
+	"github.com/patrickmn/go-cache"
+)
+
+// getInstances returns the BIRD instances of a PoP. PoPs whose proxy
+// cannot list them are treated as having a single default instance.
+func (f *Frontend) getInstances(id string) []bird.Instance {
+	if v, found := f.instances.Get(id); found {
+		return v.([]bird.Instance)
+	}
+
+	instances, err := proxyreq.InstancesRequest(id)
+	if err != nil {
+		log.Debugf("Failed to list BIRD instances of %s: %v", id, err)
+		f.instances.Set(id, []bird.Instance(nil), constant.InstancesFailedCacheDuration)
+		return nil
+	}
+
+	f.instances.Set(id, instances, cache.DefaultExpiration)
+	return instances
+}
+
+// selectInstance checks a requested instance name against the PoP's
+// instances. An empty name selects the proxy's default instance.
+func (f *Frontend) selectInstance(id, requested string) (string, bool) {
+	if requested == "" {
+		return "", true
+	}
+	if !validator.IsValidProtocol(requested) {
+		return "", false
+	}
+	for _, inst := range f.getInstances(id) {
+		if inst.Name == requested {
+			return requested, true
+		}
+	}
+	return "", false
+}
+
+// instanceForFamily picks the instance that should answer a query for the
+// given address family, preferring the requested one if it serves it.
+func (f *Frontend) instanceForFamily(id, requested string, isV6 bool) string {
+	instances := f.getInstances(id)
+	for _, inst := range instances {
+		if inst.Name == requested && inst.Serves(isV6) {
+			return requested
+		}
+	}
+	for _, inst := range instances {
+		if inst.Serves(isV6) {
+			return inst.Name
+		}
+	}
+	return requested
+}
+
+// summaryPath links to a PoP summary, keeping the selected instance
+func summaryPath(id, instance string) string {
+	if instance == "" {
+		return "/detail/" + id
+	}
+	return "/detail/" + id + "?instance=" + url.QueryEscape(instance)
+}
@@ -1,11 +1,14 @@
 package frontend
 
 import (
+	"html/template"
 	"io/fs"
 	"net/http"
 	"net/url"
 	"strings"
 
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/proxyreq"
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/tunnel"
 	"github.com/LaunchPad-Network/NetPeek/internal/service/frontend/assets"
 	"github.com/foolin/goview"
 	"github.com/foolin/goview/supports/ginview"
@@ -55,6 +58,7 @@ func (f *Frontend) setupTemplates() {
 		Root:      "",
 		Extension: "html",
 		Master:    "base.tmpl",
+		Partials:  []string{"traceroute_rows.tmpl"},
 	})
 
 	engine.SetFileHandler(func(cfg goview.Config, tplFile string) (string, error) {
@@ -66,6 +70,11 @@ func (f *Frontend) setupTemplates() {
 	})
 
 	f.engine.HTMLRender = ginview.Wrap(engine)
+
+	f.fragments, err = template.ParseFS(templatesFiles, "traceroute_rows.tmpl")
+	if err != nil {
+		log.Fatal("Failed to load template files:", err)
+	}
 }
 
 func (f *Frontend) setupRoutes() {
@@ -81,6 +90,9 @@ func (f *Frontend) setupRoutes() {
 
 	f.engine.GET("/detail/:id", f.handleDetail)
 	f.engine.GET("/detail/:id/:protocol", f.handleProtocol)
+	f.engine.GET("/stream/:id", f.handleStream)
+
+	f.engine.GET(tunnel.Path, gin.WrapH(tunnel.Handler(proxyreq.TunnelSecret)))
 
 	if viper.GetString("servers.whois") != "" {
 		f.engine.GET("/whois", f.handleWhois)
@@ -0,0 +1,128 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/LaunchPad-Network/NetPeek/constant"
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/bird"
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/capabilities"
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/proxyreqsign"
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/traceroute"
+	"github.com/LaunchPad-Network/NetPeek/internal/version"
+	"github.com/gin-gonic/gin"
+)
+
+// toolProbes are sample queries of the BIRD tools. A tool is offered if
+// the commands the requesting key may send accept its query.
+var toolProbes = []struct {
+	tool  string
+	query string
+}{
+	{capabilities.ToolSummary, "show protocols"},
+	{capabilities.ToolProtocol, "show protocols all 'probe'"},
+	{capabilities.ToolRoute, "show route for 192.0.2.1 all"},
+	{capabilities.ToolFilter, "show route filtered all protocol 'probe'"},
+}
+
+// buildCapabilities describes what a key with the given scope may do
+func buildCapabilities(ctx context.Context, scope *proxyreqsign.Scope) *capabilities.Capabilities {
+	caps := &capabilities.Capabilities{
+		Version:    version.CommitHash(),
+		BuildTime:  version.BuildTime(),
+		Instances:  []capabilities.InstanceStatus{},
+		Rejections: proxyreqsign.Rejections(),
+	}
+
+	for _, probe := range toolProbes {
+		if !scope.AllowsEndpoint("bird") {
+			break
+		}
+		if scope.AllowsCommand(allowedCommands, probe.query) {
+			caps.Tools = append(caps.Tools, probe.tool)
+		}
+	}
+
+	if traceroute.Enabled() && scope.AllowsEndpoint("traceroute") {
+		opts := traceroute.LoadOptions()
+		caps.Tools = append(caps.Tools, capabilities.ToolTraceroute,
+			capabilities.ToolTraceroute4, capabilities.ToolTraceroute6)
+		caps.Traceroute = &capabilities.TracerouteLimits{
+			MaxHops:   opts.MaxHops,
+			Count:     opts.Count,
+			MaxCount:  traceroute.MaxCount(),
+			Timeout:   int(opts.Timeout.Seconds()),
+			Size:      opts.Size,
+			Protocols: traceroute.EnabledProtocols(),
+		}
+	}
+
+	if traceroute.PingEnabled() && scope.AllowsEndpoint("ping") {
+		limits := traceroute.LoadPingLimits()
+		caps.Tools = append(caps.Tools, capabilities.ToolPing)
+		caps.Ping = &capabilities.PingLimits{
+			MaxCount:    limits.MaxCount,
+			MinInterval: int(limits.MinInterval.Milliseconds()),
+			MaxSize:     limits.MaxSize,
+		}
+	}
+
+	if caps.Traceroute != nil || caps.Ping != nil {
+		for _, src := range traceroute.Sources() {
+			caps.Sources = append(caps.Sources, capabilities.Source{
+				Name: src.Name,
+				IPv4: src.Address(false),
+				IPv6: src.Address(true),
+			})
+		}
+
+		limits := jobs.Limits()
+		stats := jobs.Stats()
+		caps.Scheduler = &capabilities.SchedulerStats{
+			MaxJobs:       limits.MaxJobs,
+			MaxJobsPerKey: limits.MaxJobsPerKey,
+			MaxQueue:      limits.MaxQueue,
+			MaxWait:       int(limits.MaxWait.Seconds()),
+			Running:       stats.Running,
+			Queued:        stats.Queued,
+			Rejections:    stats.Rejections,
+		}
+	}
+
+	for i, inst := range bird.Instances() {
+		if i == 0 {
+			limits := inst.Limits()
+			caps.Bird = capabilities.BirdLimits{
+				MaxBytes:  limits.MaxBytes,
+				MaxRoutes: limits.MaxRoutes,
+			}
+		}
+
+		st := capabilities.InstanceStatus{
+			Name:   inst.Name,
+			Family: inst.Family,
+		}
+		statusCtx, cancel := context.WithTimeout(ctx, constant.BirdStatusTimeout)
+		status, err := inst.Status(statusCtx)
+		cancel()
+		if err != nil {
+			log.Warnf("BIRD instance %q is unreachable: %v", inst.Name, err)
+			st.Error = err.Error()
+		} else {
+			st.Up = true
+			st.Version = status.Version
+			st.RouterID = status.RouterID
+		}
+		caps.Instances = append(caps.Instances, st)
+	}
+
+	return caps
+}
+
+func capabilitiesHandler(c *gin.Context) {
+	spr, ok := securityCheck(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, buildCapabilities(c.Request.Context(), spr.Scope()))
+}
@@ -0,0 +1,53 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/proxyreqsign"
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/scheduler"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lfcypo/viperx"
+)
+
+// jobs schedules the traceroutes and pings, which use raw sockets
+var jobs *scheduler.Scheduler
+
+// schedulerLimits reads and checks the scheduler section of the config
+func schedulerLimits() (scheduler.Limits, error) {
+	limits := scheduler.Limits{
+		MaxJobs:       viperx.GetInt("scheduler.max_jobs", 4),
+		MaxJobsPerKey: viperx.GetInt("scheduler.max_jobs_per_key", 2),
+		MaxQueue:      viperx.GetInt("scheduler.max_queue", 16),
+		MaxWait:       time.Duration(viperx.GetInt("scheduler.max_wait", 30)) * time.Second,
+	}
+	if err := limits.Validate(); err != nil {
+		return limits, fmt.Errorf("invalid scheduler: %w", err)
+	}
+	return limits, nil
+}
+
+// acquireJob waits for a measurement slot for the key of a request. If
+// there is none it answers the request and returns false.
+func acquireJob(c *gin.Context, spr *proxyreqsign.SignedProxyRequest) (*scheduler.Job, bool) {
+	job, err := jobs.Acquire(c.Request.Context(), spr.KeyID)
+	if err == nil {
+		return job, true
+	}
+
+	log.Warnf("%s request of key %q from %s not run: %v", spr.Kind, spr.KeyID, c.ClientIP(), err)
+	retryAfter := strconv.Itoa(int(jobs.Limits().MaxWait.Seconds()))
+	switch {
+	case errors.Is(err, scheduler.ErrQueueFull):
+		c.Header("Retry-After", retryAfter)
+		c.String(http.StatusTooManyRequests, "Too many measurements, please try again later")
+	case errors.Is(err, scheduler.ErrQueueTimeout):
+		c.Header("Retry-After", retryAfter)
+		c.String(http.StatusServiceUnavailable, "Timed out waiting for other measurements")
+	}
+	return nil, false
+}
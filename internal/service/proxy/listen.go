@@ -0,0 +1,104 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/crypto/hmac"
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/crypto/signature"
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/tlsconfig"
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/tunnel"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+)
+
+// tlsOptions returns the TLS files of the listener, nil without
+// proxy.tls.cert_file
+func tlsOptions() *tlsconfig.ServerOptions {
+	opts := &tlsconfig.ServerOptions{
+		CertFile:     viper.GetString("proxy.tls.cert_file"),
+		KeyFile:      viper.GetString("proxy.tls.key_file"),
+		ClientCAFile: viper.GetString("proxy.tls.client_ca_file"),
+	}
+	if opts.CertFile == "" {
+		return nil
+	}
+	return opts
+}
+
+// tunnelOptions returns how the proxy of node connects to the frontend,
+// nil without tunnel.url
+func tunnelOptions(node string) (*tunnel.DialOptions, error) {
+	u := viper.GetString("tunnel.url")
+	if u == "" {
+		return nil, nil
+	}
+
+	secretHex := viper.GetString("tunnel.secret")
+	if file := viper.GetString("tunnel.secret_file"); file != "" {
+		var err error
+		if secretHex, err = signature.ReadKeyFile(file); err != nil {
+			return nil, err
+		}
+	}
+	secret, err := hmac.ImportKeyHex(secretHex)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tunnel secret: %w", err)
+	}
+
+	cfg, err := tlsconfig.Client(tlsconfig.ClientOptions{
+		CAFile:   viper.GetString("tunnel.ca_file"),
+		CertFile: viper.GetString("tunnel.cert_file"),
+		KeyFile:  viper.GetString("tunnel.key_file"),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &tunnel.DialOptions{
+		URL:    u,
+		Node:   node,
+		Secret: secret,
+		TLS:    cfg,
+	}, nil
+}
+
+// ListenAndServe serves the proxy on addr, over TLS if proxy.tls is
+// configured. Certificates are picked up again when they are renewed.
+// With tunnel.url, the proxy connects to the frontend instead and only
+// listens as well if tunnel.listen is set.
+func ListenAndServe(r *gin.Engine, addr string) error {
+	tunnelOpts, err := tunnelOptions(nodeID)
+	if err != nil {
+		return err
+	}
+	if tunnelOpts != nil {
+		log.Info("Connecting to the frontend at " + tunnelOpts.URL)
+		if !viper.GetBool("tunnel.listen") {
+			tunnel.Serve(context.Background(), *tunnelOpts, r.Handler())
+			return nil
+		}
+		go tunnel.Serve(context.Background(), *tunnelOpts, r.Handler())
+	}
+
+	log.Info("Listening on " + addr)
+	opts := tlsOptions()
+	if opts == nil {
+		return r.Run(addr)
+	}
+
+	cfg, err := tlsconfig.Server(*opts)
+	if err != nil {
+		return err
+	}
+	if opts.ClientCAFile != "" {
+		log.Info("Requiring TLS client certificates")
+	}
+	srv := &http.Server{
+		Addr:      addr,
+		Handler:   r.Handler(),
+		TLSConfig: cfg,
+	}
+	return srv.ListenAndServeTLS("", "")
+}
@@ -1,83 +1,357 @@
 package proxy
 
 import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
 	"strconv"
+	"strings"
 
 	"github.com/LaunchPad-Network/NetPeek/internal/logger"
 	"github.com/LaunchPad-Network/NetPeek/internal/misc/bird"
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/cmdgrammar"
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/proxyapi"
 	"github.com/LaunchPad-Network/NetPeek/internal/misc/proxyreqsign"
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/scheduler"
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/tlsconfig"
 	"github.com/LaunchPad-Network/NetPeek/internal/misc/traceroute"
 	"github.com/LaunchPad-Network/NetPeek/internal/router"
 
 	"github.com/gin-gonic/gin"
+	"github.com/lfcypo/viperx"
+	"github.com/spf13/viper"
 )
 
 var log = logger.New("Proxy")
 
+var allowedCommands *cmdgrammar.Grammar
+
+// nodeID is the PoP this proxy serves
+var nodeID string
+
+func compileAllowedCommands() (*cmdgrammar.Grammar, error) {
+	patterns := viper.GetStringSlice("bird.allowed_commands")
+	if len(patterns) == 0 {
+		patterns = cmdgrammar.DefaultPatterns
+	}
+
+	g, err := cmdgrammar.Compile(patterns)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bird.allowed_commands: %w", err)
+	}
+	return g, nil
+}
+
 func SetupRouter() *gin.Engine {
+	var err error
+	allowedCommands, err = compileAllowedCommands()
+	if err != nil {
+		log.Fatal(err)
+	}
+	nodeID, err = resolveNodeID()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := proxyreqsign.LoadTrustedKeys(); err != nil {
+		log.Fatal(err)
+	}
+	if err := traceroute.LoadOrigins(); err != nil {
+		log.Fatal(err)
+	}
+	if err := traceroute.LoadSources(); err != nil {
+		log.Fatal(err)
+	}
+	limits, err := schedulerLimits()
+	if err != nil {
+		log.Fatal(err)
+	}
+	jobs = scheduler.New(limits)
+
 	r := router.SetupRouter()
 
-	r.GET("/bird", birdHandler)
-	r.GET("/traceroute", tracerouteHandler)
-	r.GET("/tracerouteh", tracerouteHTMLHandler)
+	r.POST("/query", queryHandler)
+	r.POST("/ping", pingHandler)
+
+	// Endpoints taking raw BIRD commands from frontends older than /query.
+	// They skip the argument validation of typed requests, so operators
+	// have to opt in.
+	if viperx.GetBool("proxy.legacy_endpoints", false) {
+		r.GET("/bird", birdHandler)
+		r.GET("/instances", instancesHandler)
+		r.GET("/capabilities", capabilitiesHandler)
+		r.GET("/traceroute", tracerouteHandler)
+		r.GET("/tracerouteh", tracerouteHTMLHandler)
+	}
 
 	return r
 }
 
-func securityCheck(c *gin.Context) (string, bool) {
-	q := c.Query("q")
+// CheckConfig validates the proxy configuration without serving
+func CheckConfig() error {
+	if _, err := compileAllowedCommands(); err != nil {
+		return err
+	}
+	id, err := resolveNodeID()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Node ID: %s\n", id)
+
+	if err := proxyreqsign.LoadTrustedKeys(); err != nil {
+		return err
+	}
+	for _, key := range proxyreqsign.TrustedKeys() {
+		fmt.Printf("Trusted key %s (%s)\n", key.ID, key.Algorithm)
+	}
+	for _, inst := range bird.Instances() {
+		fmt.Printf("BIRD instance %s\n", inst.Name)
+	}
+	if err := traceroute.LoadOrigins(); err != nil {
+		return err
+	}
+	if err := traceroute.LoadSources(); err != nil {
+		return err
+	}
+	for _, src := range traceroute.Sources() {
+		fmt.Printf("Source %s\n", src.Name)
+	}
+	if _, err := schedulerLimits(); err != nil {
+		return err
+	}
+
+	if opts := tlsOptions(); opts != nil {
+		if _, err := tlsconfig.Server(*opts); err != nil {
+			return fmt.Errorf("invalid proxy.tls: %w", err)
+		}
+		fmt.Printf("TLS certificate %s\n", opts.CertFile)
+	}
+	if opts, err := tunnelOptions(id); err != nil {
+		return fmt.Errorf("invalid tunnel: %w", err)
+	} else if opts != nil {
+		fmt.Printf("Tunnel to %s\n", opts.URL)
+	}
+	return nil
+}
+
+// resolveNodeID determines the node ID requests must be signed for. It
+// defaults to the first label of the host name.
+func resolveNodeID() (string, error) {
+	if id := viper.GetString("proxy.node_id"); id != "" {
+		return id, nil
+	}
+
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		return "", fmt.Errorf("proxy.node_id is not set and the host name is unknown")
+	}
+	id, _, _ := strings.Cut(host, ".")
+	log.Warnf("proxy.node_id is not set, using %q from the host name", id)
+	return id, nil
+}
+
+// signedRequest reads the signature parameters of a request. body is
+// the request body of POST requests, nil otherwise.
+func signedRequest(c *gin.Context, body []byte) (*proxyreqsign.SignedProxyRequest, bool) {
 	ts := c.Query("ts")
 	sig := c.Query("sig")
-	if q == "" || ts == "" || sig == "" {
+	if ts == "" || sig == "" {
 		c.String(400, "Invalid parameters")
-		return "", false
+		return nil, false
 	}
 	tsInt, err := strconv.ParseInt(ts, 10, 64)
 	if err != nil {
 		c.String(400, "Invalid parameters")
-		return "", false
+		return nil, false
 	}
-	spr := proxyreqsign.SignedProxyRequest{
-		Query:     q,
+	spr := &proxyreqsign.SignedProxyRequest{
+		Version:   c.Query("v"),
+		Method:    c.Request.Method,
+		Kind:      strings.TrimPrefix(c.FullPath(), "/"),
+		Node:      c.Query("node"),
+		KeyID:     c.Query("kid"),
+		Query:     c.Query("q"),
+		Instance:  c.Query("instance"),
+		Nonce:     c.Query("n"),
 		Ts:        tsInt,
 		Signature: sig,
+		Body:      body,
+
+		Unrestricted: c.Query("unrestricted") == "1",
 	}
-	if !spr.Verify() {
+	if err := spr.Verify(nodeID); err != nil {
+		log.Warnf("rejected %s request from %s (%s): %v", spr.Kind, c.ClientIP(), proxyreqsign.RejectionReason(err), err)
 		c.String(403, "Invalid authentication")
-		return "", false
+		return nil, false
+	}
+	return spr, true
+}
+
+// securityCheck verifies a request to one of the GET endpoints, which
+// carry the command in the q parameter
+func securityCheck(c *gin.Context) (*proxyreqsign.SignedProxyRequest, bool) {
+	if c.Query("q") == "" {
+		c.String(400, "Invalid parameters")
+		return nil, false
+	}
+	spr, ok := signedRequest(c, nil)
+	if !ok {
+		return nil, false
 	}
-	return q, true
+	if err := checkEndpoint(spr); err != nil {
+		forbidden(c, spr, spr.Query, err)
+		return nil, false
+	}
+	return spr, true
+}
+
+// scopedEndpoints maps the endpoints restricted by key scopes to the
+// permission they need. The instances and capabilities endpoints only
+// describe the proxy and are open to every trusted key.
+var scopedEndpoints = map[string]string{
+	"bird":        "bird",
+	"traceroute":  "traceroute",
+	"tracerouteh": "traceroute",
+	"ping":        "ping",
+}
+
+func checkEndpoint(spr *proxyreqsign.SignedProxyRequest) error {
+	endpoint, ok := scopedEndpoints[spr.Kind]
+	if !ok {
+		return nil
+	}
+	return spr.Scope().CheckEndpoint(endpoint)
+}
+
+// forbidden rejects a request outside the scope of its key, naming the
+// missing permission. detail describes the request in the audit log.
+func forbidden(c *gin.Context, spr *proxyreqsign.SignedProxyRequest, detail string, err error) {
+	log.Warnf("audit: rejected %s request %q of key %q from %s: %v", spr.Kind, detail, spr.KeyID, c.ClientIP(), err)
+	var permErr *proxyreqsign.PermissionError
+	if errors.As(err, &permErr) {
+		c.String(http.StatusForbidden, "Missing permission: %s", permErr.Permission)
+		return
+	}
+	c.String(http.StatusForbidden, "Forbidden")
 }
 
 func birdHandler(c *gin.Context) {
-	q, ok := securityCheck(c)
+	spr, ok := securityCheck(c)
 	if !ok {
 		return
 	}
-	c.Writer.WriteHeader(200)
-	bird.CallBirdRestricted(q, c.Writer)
+	runBird(c, spr, spr.Instance, spr.Query)
+}
+
+// runBird sends a BIRD command the key may run to an instance and
+// streams the output
+func runBird(c *gin.Context, spr *proxyreqsign.SignedProxyRequest, instance, cmd string) {
+	if err := spr.Scope().CheckCommand(allowedCommands, cmd, spr.Unrestricted); err != nil {
+		forbidden(c, spr, cmd, err)
+		return
+	}
+	c.Header("Content-Type", "text/plain; charset=utf-8")
+	call := bird.CallBirdRestricted
+	if spr.Unrestricted {
+		call = bird.CallBirdUnrestricted
+	}
+	err := call(c.Request.Context(), instance, cmd, c.Writer)
+	if err != nil {
+		writeBirdError(c, instance, err)
+	}
+}
+
+func instancesHandler(c *gin.Context) {
+	if _, ok := securityCheck(c); !ok {
+		return
+	}
+	c.JSON(http.StatusOK, bird.Instances())
+}
+
+// writeBirdError reports a failed BIRD query with its error class,
+// unless part of the output has already been sent.
+func writeBirdError(c *gin.Context, instance string, err error) {
+	if errors.Is(err, bird.ErrUnknownInstance) {
+		c.String(http.StatusNotFound, "Unknown BIRD instance")
+		return
+	}
+	if errors.Is(err, bird.ErrTruncated) {
+		// The output already ends with the marker line
+		log.Infof("%v (instance %q)", err, instance)
+		return
+	}
+
+	var replyErr *bird.ReplyError
+	if !errors.As(err, &replyErr) {
+		log.Errorf("bird error: %v", err)
+		if !c.Writer.Written() {
+			c.String(http.StatusBadGateway, err.Error())
+		}
+		return
+	}
+
+	log.Debugf("bird replied with %s: %v", replyErr.Class(), replyErr)
+	if c.Writer.Written() {
+		return
+	}
+	c.Header(bird.ErrorClassHeader, replyErr.Class())
+	c.String(http.StatusUnprocessableEntity, replyErr.Message)
 }
 
 func tracerouteHandler(c *gin.Context) {
-	q, ok := securityCheck(c)
+	spr, ok := securityCheck(c)
 	if !ok {
 		return
 	}
-	r, err := traceroute.CallTraceroute(q)
+	runTraceroute(c, spr, proxyapi.Args{Target: spr.Query})
+}
+
+// runTraceroute streams the text output of a traceroute hop by hop
+func runTraceroute(c *gin.Context, spr *proxyreqsign.SignedProxyRequest, args proxyapi.Args) {
+	job, ok := acquireJob(c, spr)
+	if !ok {
+		return
+	}
+	defer job.Release()
+
+	c.Header("Content-Type", "text/plain; charset=utf-8")
+	err := traceroute.StreamTraceroute(c.Request.Context(), args, job.ProbeID, flushWriter{c.Writer})
 	if err != nil {
 		log.Errorf("traceroute error: %v", err)
-		c.String(500, err.Error())
-		return
+		if !c.Writer.Written() {
+			c.String(500, err.Error())
+		}
 	}
-	c.String(200, r)
+}
+
+// flushWriter sends every write to the client right away
+type flushWriter struct {
+	w gin.ResponseWriter
+}
+
+func (fw flushWriter) Write(b []byte) (int, error) {
+	n, err := fw.w.Write(b)
+	fw.w.Flush()
+	return n, err
 }
 
 func tracerouteHTMLHandler(c *gin.Context) {
-	q, ok := securityCheck(c)
+	spr, ok := securityCheck(c)
 	if !ok {
 		return
 	}
-	r, err := traceroute.CallTracerouteHTML(q)
+	runTracerouteHTML(c, spr, proxyapi.Args{Target: spr.Query})
+}
+
+// runTracerouteHTML answers with the traceroute as an HTML table
+func runTracerouteHTML(c *gin.Context, spr *proxyreqsign.SignedProxyRequest, args proxyapi.Args) {
+	job, ok := acquireJob(c, spr)
+	if !ok {
+		return
+	}
+	defer job.Release()
+
+	r, err := traceroute.CallTracerouteHTML(c.Request.Context(), args, job.ProbeID)
 	if err != nil {
 		log.Errorf("traceroute error: %v", err)
 		c.String(500, err.Error())
@@ -0,0 +1,145 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/LaunchPad-Network/NetPeek/constant"
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/bird"
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/proxyapi"
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/proxyreqsign"
+	"github.com/LaunchPad-Network/NetPeek/internal/misc/traceroute"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readRequest reads and verifies a typed request sent as a JSON envelope.
+// The body is covered by the signature.
+func readRequest(c *gin.Context) (*proxyreqsign.SignedProxyRequest, *proxyapi.Request, bool) {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, constant.ProxyRequestMaxBodySize+1))
+	if err != nil {
+		c.String(http.StatusBadRequest, "Invalid request body")
+		return nil, nil, false
+	}
+	if len(body) > constant.ProxyRequestMaxBodySize {
+		c.String(http.StatusRequestEntityTooLarge, "Request body too large")
+		return nil, nil, false
+	}
+
+	spr, ok := signedRequest(c, body)
+	if !ok {
+		return nil, nil, false
+	}
+	req, err := proxyapi.Decode(body)
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return nil, nil, false
+	}
+	if endpoint := req.Endpoint(); endpoint != "" {
+		if err := spr.Scope().CheckEndpoint(endpoint); err != nil {
+			forbidden(c, spr, req.Type, err)
+			return nil, nil, false
+		}
+	}
+	return spr, req, true
+}
+
+// queryHandler runs a typed request. BIRD commands are built from its
+// arguments.
+func queryHandler(c *gin.Context) {
+	spr, req, ok := readRequest(c)
+	if !ok {
+		return
+	}
+
+	switch {
+	case req.Type == proxyapi.TypeInstances:
+		c.JSON(http.StatusOK, bird.Instances())
+	case req.Type == proxyapi.TypeCapabilities:
+		c.JSON(http.StatusOK, buildCapabilities(c.Request.Context(), spr.Scope()))
+	case req.IsBird():
+		cmd, err := req.BirdCommand()
+		if err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		runBird(c, spr, req.Instance, cmd)
+	case req.Type == proxyapi.TypeTraceroute && req.Args.Format == proxyapi.FormatJSON:
+		runTracerouteJSON(c, spr, req.Args)
+	case req.Type == proxyapi.TypeTraceroute && req.Args.Format == proxyapi.FormatJSONLines:
+		runTracerouteJSONLines(c, spr, req.Args)
+	case req.Type == proxyapi.TypeTraceroute && req.Args.Format == proxyapi.FormatHTML:
+		runTracerouteHTML(c, spr, req.Args)
+	case req.Type == proxyapi.TypeTraceroute:
+		runTraceroute(c, spr, req.Args)
+	case req.Type == proxyapi.TypePing:
+		runPing(c, spr, req.Args)
+	default:
+		c.String(http.StatusBadRequest, "Unknown query type")
+	}
+}
+
+// runTracerouteJSON answers with the traceroute document
+func runTracerouteJSON(c *gin.Context, spr *proxyreqsign.SignedProxyRequest, args proxyapi.Args) {
+	job, ok := acquireJob(c, spr)
+	if !ok {
+		return
+	}
+	defer job.Release()
+
+	res, err := traceroute.Traceroute(c.Request.Context(), args, job.ProbeID)
+	if err != nil {
+		log.Errorf("traceroute error: %v", err)
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+// runTracerouteJSONLines streams the traceroute document hop by hop
+func runTracerouteJSONLines(c *gin.Context, spr *proxyreqsign.SignedProxyRequest, args proxyapi.Args) {
+	job, ok := acquireJob(c, spr)
+	if !ok {
+		return
+	}
+	defer job.Release()
+
+	c.Header("Content-Type", "application/jsonl; charset=utf-8")
+	err := traceroute.StreamTracerouteJSON(c.Request.Context(), args, job.ProbeID, flushWriter{c.Writer})
+	if err != nil {
+		log.Errorf("traceroute error: %v", err)
+		if !c.Writer.Written() {
+			c.String(http.StatusInternalServerError, err.Error())
+		}
+	}
+}
+
+// pingHandler runs a ping request, the only type it accepts
+func pingHandler(c *gin.Context) {
+	spr, req, ok := readRequest(c)
+	if !ok {
+		return
+	}
+	if req.Type != proxyapi.TypePing {
+		c.String(http.StatusBadRequest, "Not a ping request")
+		return
+	}
+	runPing(c, spr, req.Args)
+}
+
+// runPing answers with the probes and statistics of a ping as JSON
+func runPing(c *gin.Context, spr *proxyreqsign.SignedProxyRequest, args proxyapi.Args) {
+	job, ok := acquireJob(c, spr)
+	if !ok {
+		return
+	}
+	defer job.Release()
+
+	res, err := traceroute.Ping(c.Request.Context(), args, job.ProbeID)
+	if err != nil {
+		log.Errorf("ping error: %v", err)
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}